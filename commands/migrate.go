@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"blogpost/config"
+	driver "blogpost/drivers"
+	migrators "blogpost/updates"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = `usage: blogpost migrate <command>
+
+commands:
+  status          list the applied and pending migrations
+  up [N]          apply all pending migrations, or the next N
+  down [N]        roll back the last migration, or the last N
+  redo            roll back the last migration and apply it again
+  force <N>       mark the dirty migration N as applied after repairing the schema by hand
+  create <name>   scaffold a new migration file in ./updates (or the directory given as third argument)`
+
+// Migrate runs the migrate subcommand with the arguments following "migrate"
+func Migrate(cfg *config.Config, logger *log.Logger, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	if args[0] == "create" {
+		if len(args) < 2 {
+			return fmt.Errorf("usage: blogpost migrate create <name> [dir]")
+		}
+
+		dir := "updates"
+		if len(args) > 2 {
+			dir = args[2]
+		}
+
+		path, err := migrators.Create(dir, args[1])
+		if err != nil {
+			return err
+		}
+
+		logger.Printf("Created migration %s", path)
+		return nil
+	}
+
+	switch args[0] {
+	case "status", "up", "down", "redo", "force":
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+
+	if err := cfg.ValidateDatabase(); err != nil {
+		return err
+	}
+
+	migrator := migrators.NewMigrator(driver.SQLDriver(cfg.Database), logger, cfg.Database.MigrationLockTimeout)
+
+	switch args[0] {
+	case "status":
+		return printStatus(migrator)
+	case "up":
+		n, err := stepCount(args, 0)
+		if err != nil {
+			return err
+		}
+		return migrator.Up(n)
+	case "down":
+		n, err := stepCount(args, 1)
+		if err != nil {
+			return err
+		}
+		return migrator.Down(n)
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: blogpost migrate force <version>")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version: %v", args[1])
+		}
+		return migrator.Force(version)
+	default:
+		return migrator.Redo()
+	}
+}
+
+// stepCount reads the optional N argument of up and down
+func stepCount(args []string, fallback int) (int, error) {
+	if len(args) < 2 {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid number of steps: %v", args[1])
+	}
+
+	return n, nil
+}
+
+func printStatus(migrator *migrators.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+
+	for _, status := range statuses {
+		state := "pending"
+		switch {
+		case status.Dirty:
+			state = "dirty"
+		case status.Unknown:
+			state = "applied (not in binary)"
+		case status.Modified:
+			state = "applied (checksum mismatch)"
+		case status.Applied:
+			state = "applied"
+		}
+
+		appliedAt := "-"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"blogpost/oidc"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// OIDCMock serves a mock OpenID Connect provider for trying the OIDC login locally, it logs anybody in
+func OIDCMock(logger *log.Logger, args []string) error {
+	addr := "localhost:9000"
+	if len(args) > 0 {
+		addr = args[0]
+	}
+
+	issuer := "http://" + addr
+	mock, err := oidc.NewMock(issuer)
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Mock OIDC provider with the issuer %v, do not expose it", issuer)
+	if err := http.ListenAndServe(addr, mock); err != nil {
+		return fmt.Errorf("error serving the mock OIDC provider: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"blogpost/config"
+	driver "blogpost/drivers"
+	"blogpost/seeds"
+	migrators "blogpost/updates"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Seed inserts the named seed sets, or the default ones when no names are given
+func Seed(cfg *config.Config, logger *log.Logger, names []string) error {
+	if len(names) == 1 && names[0] == "list" {
+		for _, seed := range seeds.Sets() {
+			marker := ""
+			if seed.Default {
+				marker = " (default)"
+			}
+			fmt.Printf("%-12s %s%s\n", seed.Name, seed.Description, marker)
+		}
+		return nil
+	}
+
+	if err := cfg.ValidateDatabase(); err != nil {
+		return err
+	}
+
+	if err := cfg.ValidateAdmin(); err != nil {
+		return err
+	}
+
+	db := driver.SQLDriver(cfg.Database)
+
+	pending, err := migrators.NewMigrator(db, logger, cfg.Database.MigrationLockTimeout).Pending()
+	if err != nil {
+		return err
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("%d pending migrations, run `blogpost migrate up` before seeding", pending)
+	}
+
+	if len(names) == 0 {
+		logger.Printf("Seeding the default sets")
+	} else {
+		logger.Printf("Seeding %s", strings.Join(names, ", "))
+	}
+
+	return seeds.Run(db, cfg, logger, names)
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"blogpost/config"
+	driver "blogpost/drivers"
+	"blogpost/mailer"
+	"blogpost/middleware"
+	"blogpost/passwords"
+	"blogpost/repository"
+	"blogpost/router"
+	migrators "blogpost/updates"
+	"fmt"
+	"log"
+)
+
+// Serve connects to the database and starts the HTTP server
+func Serve(cfg *config.Config, logger *log.Logger) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	keys, err := middleware.NewKeySet(cfg.JWT)
+	if err != nil {
+		return fmt.Errorf("error loading the signing keys: %v", err)
+	}
+
+	policy, err := passwords.LoadPolicy(cfg.Password)
+	if err != nil {
+		return err
+	}
+
+	mail, err := mailer.New(cfg.Mail, logger)
+	if err != nil {
+		return err
+	}
+
+	dbConnection := driver.SQLDriver(cfg.Database)
+	migrator := migrators.NewMigrator(dbConnection, logger, cfg.Database.MigrationLockTimeout)
+
+	if cfg.Database.AutoMigrate {
+		if err := migrator.Up(0); err != nil {
+			return fmt.Errorf("error migrating the database: %v", err)
+		}
+	} else {
+		pending, err := migrator.Pending()
+		if err != nil {
+			return err
+		}
+
+		if pending > 0 {
+			logger.Printf("%d pending migrations, run `blogpost migrate up` to apply them", pending)
+		}
+	}
+
+	searchBackend, err := repository.NewSearchBackend(dbConnection, cfg.Search, logger)
+	if err != nil {
+		return fmt.Errorf("error preparing the post search: %v", err)
+	}
+
+	connection := repository.NewDbConnection(dbConnection, logger, cfg, keys, policy, searchBackend)
+	go connection.RunPublisher(cfg.Posts.PublishInterval)
+
+	router.Routing(connection, mail, cfg)
+	return nil
+}
@@ -0,0 +1,577 @@
+package config
+
+import (
+	"blogpost/utilities"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting the service needs at startup. Values are resolved
+// in the order: defaults, config file, environment variables, command line flags.
+type Config struct {
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	JWT      JWTConfig      `yaml:"jwt" toml:"jwt"`
+	Admin    AdminConfig    `yaml:"admin" toml:"admin"`
+	Mail     MailConfig     `yaml:"mail" toml:"mail"`
+	Accounts AccountsConfig `yaml:"accounts" toml:"accounts"`
+	Password PasswordConfig `yaml:"password" toml:"password"`
+	OIDC     OIDCConfig     `yaml:"oidc" toml:"oidc"`
+	Search   SearchConfig   `yaml:"search" toml:"search"`
+	Posts    PostsConfig    `yaml:"posts" toml:"posts"`
+}
+
+type ServerConfig struct {
+	Port    int    `yaml:"port" toml:"port" validate:"required,min=1,max=65535"`
+	LogFile string `yaml:"log_file" toml:"log_file" validate:"required"`
+	// PublicURL is where users reach the service, it is used for the links in the mails
+	PublicURL string `yaml:"public_url" toml:"public_url" validate:"required,url"`
+}
+
+type DatabaseConfig struct {
+	Driver      string `yaml:"driver" toml:"driver" validate:"required,oneof=mysql postgres sqlite"`
+	DSN         string `yaml:"dsn" toml:"dsn" validate:"required"`
+	AutoMigrate bool   `yaml:"auto_migrate" toml:"auto_migrate"`
+	// MigrationLockTimeout is how long an instance waits for another one to finish migrating
+	MigrationLockTimeout time.Duration `yaml:"migration_lock_timeout" toml:"migration_lock_timeout" validate:"min=0"`
+}
+
+// defaultDSNs is used when a driver is selected without a connection string
+var defaultDSNs = map[string]string{
+	"mysql":    "root:password@tcp(localhost:3306)/blogpost?parseTime=true",
+	"postgres": "host=localhost user=postgres password=password dbname=blogpost port=5432 sslmode=disable",
+	"sqlite":   "blogpost.db",
+}
+
+type JWTConfig struct {
+	// Secret signs the tokens with HS256 when no SigningKey is configured
+	Secret string `yaml:"secret" toml:"secret" validate:"omitempty,min=16"`
+	// SigningKey is a PEM file with the RSA (RS256) or Ed25519 (EdDSA) private key the tokens are signed with
+	SigningKey string `yaml:"signing_key" toml:"signing_key" validate:"omitempty,file"`
+	// VerificationKeys are PEM files with previous public keys whose tokens are still accepted during a rotation
+	VerificationKeys []string `yaml:"verification_keys" toml:"verification_keys" validate:"dive,file"`
+	// AccessTTL is the lifetime of the access tokens, RefreshTTL the lifetime of the refresh tokens used to renew them
+	AccessTTL  time.Duration `yaml:"access_ttl" toml:"access_ttl" validate:"required,min=1s"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" toml:"refresh_ttl" validate:"required,gtfield=AccessTTL"`
+}
+
+// MailConfig selects how the mails to the users are delivered
+type MailConfig struct {
+	Driver string `yaml:"driver" toml:"driver" validate:"required,oneof=outbox smtp"`
+	From   string `yaml:"from" toml:"from" validate:"required"`
+	// OutboxDir is where the outbox driver writes the mails
+	OutboxDir string     `yaml:"outbox_dir" toml:"outbox_dir" validate:"required_if=Driver outbox"`
+	SMTP      SMTPConfig `yaml:"smtp" toml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port" validate:"omitempty,min=1,max=65535"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+// AccountsConfig holds the settings of the account self service
+type AccountsConfig struct {
+	// ResetTTL is how long a password reset link stays valid
+	ResetTTL time.Duration `yaml:"reset_ttl" toml:"reset_ttl" validate:"required,min=1m"`
+	// VerificationTTL is how long an email verification link stays valid
+	VerificationTTL time.Duration `yaml:"verification_ttl" toml:"verification_ttl" validate:"required,min=1m"`
+	// MaxLoginFailures locks an account after that many failed logins in a row, MaxIPLoginFailures does the same
+	// for a client address trying many accounts
+	MaxLoginFailures   int `yaml:"max_login_failures" toml:"max_login_failures" validate:"required,min=1"`
+	MaxIPLoginFailures int `yaml:"max_ip_login_failures" toml:"max_ip_login_failures" validate:"required,min=1"`
+	// LockoutDuration is how long a locked account or address stays locked, failures older than that are forgotten
+	LockoutDuration time.Duration `yaml:"lockout_duration" toml:"lockout_duration" validate:"required,min=1s"`
+	// TOTPIssuer is the name authenticator apps show for the two-factor codes
+	TOTPIssuer string `yaml:"totp_issuer" toml:"totp_issuer" validate:"required"`
+}
+
+// PasswordConfig is the policy new passwords must satisfy and how they are hashed
+type PasswordConfig struct {
+	MinLength int `yaml:"min_length" toml:"min_length" validate:"required,min=8"`
+	MaxLength int `yaml:"max_length" toml:"max_length" validate:"required,gtefield=MinLength"`
+	// BreachedList is a file of known breached passwords, or their SHA-1 hashes, that are refused
+	BreachedList string `yaml:"breached_list" toml:"breached_list" validate:"omitempty,file"`
+	// Algorithm hashes the new passwords, hashes made with the other one or older parameters are upgraded on login
+	Algorithm  string `yaml:"algorithm" toml:"algorithm" validate:"required,oneof=argon2id bcrypt"`
+	BcryptCost int    `yaml:"bcrypt_cost" toml:"bcrypt_cost" validate:"required,min=10,max=31"`
+	// Argon2Memory is in KiB
+	Argon2Memory      uint32 `yaml:"argon2_memory" toml:"argon2_memory" validate:"required,min=8192"`
+	Argon2Iterations  uint32 `yaml:"argon2_iterations" toml:"argon2_iterations" validate:"required,min=1"`
+	Argon2Parallelism uint8  `yaml:"argon2_parallelism" toml:"argon2_parallelism" validate:"required,min=1"`
+}
+
+// OIDCConfig enables the login through an OpenID Connect provider, it is disabled while Issuer is empty
+type OIDCConfig struct {
+	Issuer       string `yaml:"issuer" toml:"issuer" validate:"omitempty,url"`
+	ClientID     string `yaml:"client_id" toml:"client_id" validate:"required_with=Issuer"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret"`
+	// RedirectURL is the callback registered with the provider, it defaults to the callback under the public URL
+	RedirectURL string   `yaml:"redirect_url" toml:"redirect_url" validate:"omitempty,url"`
+	Scopes      []string `yaml:"scopes" toml:"scopes"`
+	// RoleClaim names the ID token claim, a string or a list, whose values RoleMapping maps to roles.
+	// The role of a linked user follows the mapping on every login, users without a match get DefaultRole when created.
+	RoleClaim   string            `yaml:"role_claim" toml:"role_claim"`
+	RoleMapping map[string]string `yaml:"role_mapping" toml:"role_mapping"`
+	DefaultRole string            `yaml:"default_role" toml:"default_role" validate:"required"`
+	// AutoCreate creates an account for a verified email that has none yet, otherwise only existing accounts can log in
+	AutoCreate bool `yaml:"auto_create" toml:"auto_create"`
+}
+
+// SearchConfig picks the backend of the post search: the full-text search of the database, the in-process index,
+// or with auto the database for MySQL and PostgreSQL and the index for SQLite
+type SearchConfig struct {
+	Backend string `yaml:"backend" toml:"backend" validate:"required,oneof=auto database memory"`
+}
+
+// PostsConfig holds the settings of the post lifecycle
+type PostsConfig struct {
+	// PublishInterval is how often the scheduled posts that are due get published
+	PublishInterval time.Duration `yaml:"publish_interval" toml:"publish_interval" validate:"required,min=1s"`
+}
+
+// AdminConfig is the account created by the admin seed set
+type AdminConfig struct {
+	Email    string `yaml:"email" toml:"email" validate:"omitempty,email"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+// Default returns the configuration used when nothing else is provided
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:    8000,
+			LogFile: "log.log",
+		},
+		Database: DatabaseConfig{
+			Driver:               "mysql",
+			MigrationLockTimeout: time.Minute,
+		},
+		JWT: JWTConfig{
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		Mail: MailConfig{
+			Driver:    "outbox",
+			From:      "blogpost <no-reply@localhost>",
+			OutboxDir: "outbox",
+			SMTP:      SMTPConfig{Port: 587},
+		},
+		Accounts: AccountsConfig{
+			ResetTTL:           time.Hour,
+			VerificationTTL:    24 * time.Hour,
+			MaxLoginFailures:   5,
+			MaxIPLoginFailures: 50,
+			LockoutDuration:    15 * time.Minute,
+			TOTPIssuer:         "blogpost",
+		},
+		Password: PasswordConfig{
+			MinLength:         10,
+			MaxLength:         128,
+			Algorithm:         "argon2id",
+			BcryptCost:        12,
+			Argon2Memory:      64 * 1024,
+			Argon2Iterations:  3,
+			Argon2Parallelism: 2,
+		},
+		OIDC: OIDCConfig{
+			Scopes:      []string{"openid", "email", "profile"},
+			DefaultRole: "member",
+			AutoCreate:  true,
+		},
+		Search: SearchConfig{
+			Backend: "auto",
+		},
+		Posts: PostsConfig{
+			PublishInterval: time.Minute,
+		},
+	}
+}
+
+// Load builds the configuration from the config file, the environment and the given command line arguments.
+// Flags may appear anywhere in args, the remaining positional arguments are returned alongside the configuration.
+// The configuration is not validated, callers validate the parts they need.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("blogpost", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("BLOGPOST_CONFIG"), "path to a YAML or TOML config file")
+	port := fs.Int("port", 0, "port the HTTP server listens on")
+	logFile := fs.String("log-file", "", "file the application log is appended to")
+	publicURL := fs.String("public-url", "", "URL the users reach the service at, used in the links of the mails")
+	dbDriver := fs.String("db-driver", "", "database backend: mysql, postgres or sqlite")
+	dsn := fs.String("dsn", "", "database connection string")
+	autoMigrate := fs.Bool("auto-migrate", false, "apply pending migrations when the server starts")
+	lockTimeout := fs.Duration("migration-lock-timeout", 0, "how long to wait for another instance to finish migrating")
+	jwtSecret := fs.String("jwt-secret", "", "HMAC secret used to sign the tokens when no signing key is set")
+	signingKey := fs.String("jwt-signing-key", "", "PEM file with the RSA or Ed25519 private key used to sign the tokens")
+	verificationKeys := fs.String("jwt-verification-keys", "", "comma separated PEM files with previous public keys still accepted")
+	accessTTL := fs.Duration("access-ttl", 0, "lifetime of the access tokens")
+	refreshTTL := fs.Duration("refresh-ttl", 0, "lifetime of the refresh tokens")
+	adminEmail := fs.String("admin-email", "", "mail of the bootstrap admin created by the admin seed set")
+	mailDriver := fs.String("mail-driver", "", "how mails are delivered: outbox or smtp")
+	mailOutbox := fs.String("mail-outbox", "", "directory the outbox mail driver writes to")
+
+	positional := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, nil, err
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	// only the flags that were passed explicitly override the other sources
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Server.Port = *port
+		case "log-file":
+			cfg.Server.LogFile = *logFile
+		case "public-url":
+			cfg.Server.PublicURL = *publicURL
+		case "db-driver":
+			cfg.Database.Driver = *dbDriver
+		case "dsn":
+			cfg.Database.DSN = *dsn
+		case "auto-migrate":
+			cfg.Database.AutoMigrate = *autoMigrate
+		case "migration-lock-timeout":
+			cfg.Database.MigrationLockTimeout = *lockTimeout
+		case "jwt-secret":
+			cfg.JWT.Secret = *jwtSecret
+		case "jwt-signing-key":
+			cfg.JWT.SigningKey = *signingKey
+		case "jwt-verification-keys":
+			cfg.JWT.VerificationKeys = splitList(*verificationKeys)
+		case "access-ttl":
+			cfg.JWT.AccessTTL = *accessTTL
+		case "refresh-ttl":
+			cfg.JWT.RefreshTTL = *refreshTTL
+		case "admin-email":
+			cfg.Admin.Email = *adminEmail
+		case "mail-driver":
+			cfg.Mail.Driver = *mailDriver
+		case "mail-outbox":
+			cfg.Mail.OutboxDir = *mailOutbox
+		}
+	})
+
+	if cfg.Database.DSN == "" {
+		cfg.Database.DSN = defaultDSNs[cfg.Database.Driver]
+	}
+
+	if cfg.Server.PublicURL == "" {
+		cfg.Server.PublicURL = fmt.Sprintf("http://localhost:%d", cfg.Server.Port)
+	}
+	cfg.Server.PublicURL = strings.TrimSuffix(cfg.Server.PublicURL, "/")
+
+	if cfg.OIDC.RedirectURL == "" {
+		cfg.OIDC.RedirectURL = cfg.Server.PublicURL + "/blogpost/v1/oidc/callback"
+	}
+
+	return cfg, positional, nil
+}
+
+// Validate checks that the resolved configuration can be used to start the service
+func (cfg *Config) Validate() error {
+	if err := utilities.ValidateStruct(cfg); err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	if cfg.Mail.Driver == "smtp" && cfg.Mail.SMTP.Host == "" {
+		return fmt.Errorf("invalid configuration: mail.smtp.host is required by the smtp mail driver")
+	}
+
+	if cfg.OIDC.Issuer != "" && !slices.Contains(cfg.OIDC.Scopes, "openid") {
+		return fmt.Errorf("invalid configuration: oidc.scopes must include openid")
+	}
+
+	if cfg.Search.Backend == "database" && cfg.Database.Driver == "sqlite" {
+		return fmt.Errorf("invalid configuration: sqlite has no full-text search, use the memory or auto search backend")
+	}
+
+	if cfg.JWT.Secret == "" && cfg.JWT.SigningKey == "" {
+		return fmt.Errorf("invalid configuration: either jwt.secret or jwt.signing_key is required")
+	}
+
+	return nil
+}
+
+// ValidateDatabase checks only the settings needed to connect to the database
+func (cfg *Config) ValidateDatabase() error {
+	if err := utilities.ValidateStruct(cfg.Database); err != nil {
+		return fmt.Errorf("invalid database configuration: %v", err)
+	}
+
+	return nil
+}
+
+// ValidateAdmin checks the bootstrap admin settings
+func (cfg *Config) ValidateAdmin() error {
+	if err := utilities.ValidateStruct(cfg.Admin); err != nil {
+		return fmt.Errorf("invalid admin configuration: %v", err)
+	}
+
+	return nil
+}
+
+// Addr returns the address the HTTP server listens on
+func (cfg *Config) Addr() string {
+	return fmt.Sprintf(":%d", cfg.Server.Port)
+}
+
+func (cfg *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file format: %v", path)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %v: %v", path, err)
+	}
+
+	return nil
+}
+
+func (cfg *Config) loadEnv() error {
+	if value, ok := os.LookupEnv("BLOGPOST_PORT"); ok {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_PORT: %v", err)
+		}
+		cfg.Server.Port = port
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_LOG_FILE"); ok {
+		cfg.Server.LogFile = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_PUBLIC_URL"); ok {
+		cfg.Server.PublicURL = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_DB_DRIVER"); ok {
+		cfg.Database.Driver = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_DB_DSN"); ok {
+		cfg.Database.DSN = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_AUTO_MIGRATE"); ok {
+		autoMigrate, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_AUTO_MIGRATE: %v", err)
+		}
+		cfg.Database.AutoMigrate = autoMigrate
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_MIGRATION_LOCK_TIMEOUT"); ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_MIGRATION_LOCK_TIMEOUT: %v", err)
+		}
+		cfg.Database.MigrationLockTimeout = timeout
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_JWT_SECRET"); ok {
+		cfg.JWT.Secret = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_JWT_SIGNING_KEY"); ok {
+		cfg.JWT.SigningKey = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_JWT_VERIFICATION_KEYS"); ok {
+		cfg.JWT.VerificationKeys = splitList(value)
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_ACCESS_TTL"); ok {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_ACCESS_TTL: %v", err)
+		}
+		cfg.JWT.AccessTTL = ttl
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_REFRESH_TTL"); ok {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_REFRESH_TTL: %v", err)
+		}
+		cfg.JWT.RefreshTTL = ttl
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_ADMIN_EMAIL"); ok {
+		cfg.Admin.Email = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_ADMIN_PASSWORD"); ok {
+		cfg.Admin.Password = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_MAIL_DRIVER"); ok {
+		cfg.Mail.Driver = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_MAIL_FROM"); ok {
+		cfg.Mail.From = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_MAIL_OUTBOX"); ok {
+		cfg.Mail.OutboxDir = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_SMTP_HOST"); ok {
+		cfg.Mail.SMTP.Host = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_SMTP_PORT"); ok {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_SMTP_PORT: %v", err)
+		}
+		cfg.Mail.SMTP.Port = port
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_SMTP_USERNAME"); ok {
+		cfg.Mail.SMTP.Username = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_SMTP_PASSWORD"); ok {
+		cfg.Mail.SMTP.Password = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_RESET_TTL"); ok {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_RESET_TTL: %v", err)
+		}
+		cfg.Accounts.ResetTTL = ttl
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_VERIFICATION_TTL"); ok {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_VERIFICATION_TTL: %v", err)
+		}
+		cfg.Accounts.VerificationTTL = ttl
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_PASSWORD_MIN_LENGTH"); ok {
+		length, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_PASSWORD_MIN_LENGTH: %v", err)
+		}
+		cfg.Password.MinLength = length
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_BREACHED_PASSWORDS"); ok {
+		cfg.Password.BreachedList = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_PASSWORD_HASH"); ok {
+		cfg.Password.Algorithm = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_MAX_LOGIN_FAILURES"); ok {
+		failures, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_MAX_LOGIN_FAILURES: %v", err)
+		}
+		cfg.Accounts.MaxLoginFailures = failures
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_MAX_IP_LOGIN_FAILURES"); ok {
+		failures, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_MAX_IP_LOGIN_FAILURES: %v", err)
+		}
+		cfg.Accounts.MaxIPLoginFailures = failures
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_LOCKOUT_DURATION"); ok {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_LOCKOUT_DURATION: %v", err)
+		}
+		cfg.Accounts.LockoutDuration = duration
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_OIDC_ISSUER"); ok {
+		cfg.OIDC.Issuer = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_OIDC_CLIENT_ID"); ok {
+		cfg.OIDC.ClientID = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_OIDC_CLIENT_SECRET"); ok {
+		cfg.OIDC.ClientSecret = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_OIDC_REDIRECT_URL"); ok {
+		cfg.OIDC.RedirectURL = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_SEARCH_BACKEND"); ok {
+		cfg.Search.Backend = value
+	}
+
+	if value, ok := os.LookupEnv("BLOGPOST_PUBLISH_INTERVAL"); ok {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid BLOGPOST_PUBLISH_INTERVAL: %v", err)
+		}
+		cfg.Posts.PublishInterval = interval
+	}
+
+	return nil
+}
+
+// splitList splits a comma separated setting, ignoring empty entries
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,127 @@
+package diff
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// the kinds of the operations of a diff
+const (
+	Equal  = "equal"
+	Insert = "insert"
+	Delete = "delete"
+)
+
+// maxCells bounds the table of the longest common subsequence, a larger change is shown as replaced as a whole
+const maxCells = 4_000_000
+
+// Op is a piece of text both versions share, or that only the old or the new one has
+type Op struct {
+	Kind string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Words compares two texts word by word, whitespace included. Joining the equal and delete operations gives the old
+// text back, joining the equal and insert operations the new one.
+func Words(before, after string) []Op {
+	from, to := split(before), split(after)
+
+	// the common start and end need no table
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+
+	ops := &builder{ops: []Op{}}
+	ops.add(Equal, from[:prefix]...)
+	ops.changes(from[prefix:len(from)-suffix], to[prefix:len(to)-suffix])
+	ops.add(Equal, from[len(from)-suffix:]...)
+	return ops.ops
+}
+
+// builder merges the consecutive words of the same kind into one operation
+type builder struct {
+	ops []Op
+}
+
+func (b *builder) add(kind string, words ...string) {
+	for _, word := range words {
+		if last := len(b.ops) - 1; last >= 0 && b.ops[last].Kind == kind {
+			b.ops[last].Text += word
+			continue
+		}
+		b.ops = append(b.ops, Op{Kind: kind, Text: word})
+	}
+}
+
+// changes walks the longest common subsequence of the words, removals come before the insertions replacing them
+func (b *builder) changes(from, to []string) {
+	if len(from) == 0 || len(to) == 0 || len(from)*len(to) > maxCells {
+		b.add(Delete, from...)
+		b.add(Insert, to...)
+		return
+	}
+
+	// common[i*width+j] is the length of the longest common subsequence of from[i:] and to[j:]
+	width := len(to) + 1
+	common := make([]int32, (len(from)+1)*width)
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			switch {
+			case from[i] == to[j]:
+				common[i*width+j] = common[(i+1)*width+j+1] + 1
+			case common[(i+1)*width+j] >= common[i*width+j+1]:
+				common[i*width+j] = common[(i+1)*width+j]
+			default:
+				common[i*width+j] = common[i*width+j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			b.add(Equal, from[i])
+			i++
+			j++
+		case common[(i+1)*width+j] >= common[i*width+j+1]:
+			b.add(Delete, from[i])
+			i++
+		default:
+			b.add(Insert, to[j])
+			j++
+		}
+	}
+
+	b.add(Delete, from[i:]...)
+	b.add(Insert, to[j:]...)
+}
+
+// split cuts the text into runs of whitespace and runs of everything else
+func split(text string) []string {
+	words := []string{}
+	start := 0
+	for i, r := range text {
+		if i > start && unicode.IsSpace(r) != isSpaceAt(text, start) {
+			words = append(words, text[start:i])
+			start = i
+		}
+	}
+
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+
+	return words
+}
+
+func isSpaceAt(text string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return unicode.IsSpace(r)
+}
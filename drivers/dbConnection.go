@@ -1,21 +1,61 @@
 package driver
 
 import (
+	"blogpost/config"
 	"fmt"
+	"strings"
 
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func SQLDriver() *gorm.DB {
+func SQLDriver(cfg config.DatabaseConfig) *gorm.DB {
 	var err error
 
-	dsn := "root:password@tcp(localhost:3306)/blogpost?parseTime=true"
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	dialector, err := Dialector(cfg)
 	if err != nil {
 		panic(err)
 	}
 
-	fmt.Println("Connection Established")
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		panic(err)
+	}
+
+	if cfg.Driver == "sqlite" {
+		// SQLite allows a single writer, so serialise access through one connection
+		sqlDB, err := db.DB()
+		if err != nil {
+			panic(err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	fmt.Printf("Connection Established (%s)\n", cfg.Driver)
 	return db
 }
+
+// Dialector returns the gorm dialector for the configured database backend
+func Dialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "mysql":
+		return mysql.Open(cfg.DSN), nil
+	case "postgres":
+		return postgres.Open(cfg.DSN), nil
+	case "sqlite":
+		dsn := cfg.DSN
+		// foreign keys are off by default in SQLite
+		if !strings.Contains(dsn, "_foreign_keys") && !strings.Contains(dsn, "_fk") {
+			if strings.Contains(dsn, "?") {
+				dsn += "&_foreign_keys=on"
+			} else {
+				dsn += "?_foreign_keys=on"
+			}
+		}
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %v", cfg.Driver)
+	}
+}
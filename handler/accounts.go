@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"blogpost/mailer"
+	"blogpost/middleware"
+	"blogpost/passwords"
+	"blogpost/repository"
+	"blogpost/utilities"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ForgotPassword mails a password reset link. The response is the same whether or not the mail belongs to a user.
+func (h *Handler) ForgotPassword(c *fiber.Ctx) error {
+	body := struct {
+		Mail string `json:"mail" form:"mail" validate:"required,email"`
+	}{}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	user, token, err := h.Repo.CreatePasswordReset(body.Mail)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error creating the password reset"})
+	}
+
+	if user != nil {
+		link := fmt.Sprintf("%s/blogpost/v1/password/reset?token=%s", h.PublicURL, url.QueryEscape(token))
+		err := h.Mailer.Send(mailer.Message{
+			To:      user.Mail,
+			Subject: "Reset your blogpost password",
+			Body: fmt.Sprintf("A password reset was requested for your blogpost account.\n\n"+
+				"Send your new password with this token to %s within %v:\n\n%s\n\n"+
+				"If you did not request it you can ignore this mail.\n", link, h.Config.Accounts.ResetTTL, token),
+		})
+		if err != nil {
+			h.Logger.Printf("Error mailing the password reset to the user with ID %v: %v", user.ID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error sending the password reset mail"})
+		}
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "If the mail belongs to an account, a password reset link was sent to it"})
+}
+
+// VerifyEmail is the link mailed at signup, it marks the mail verified so the user can log in
+func (h *Handler) VerifyEmail(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "token is required"})
+	}
+
+	if err := h.Repo.VerifyEmail(token); err != nil {
+		if errors.Is(err, repository.ErrInvalidUserToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid or expired token, request a new verification mail"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error verifying the mail"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Mail verified, you can log in now"})
+}
+
+// ResendVerification mails a new verification link, earlier links stop working.
+// The response is the same whether or not the mail belongs to an unverified account.
+func (h *Handler) ResendVerification(c *fiber.Ctx) error {
+	body := struct {
+		Mail string `json:"mail" form:"mail" validate:"required,email"`
+	}{}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.sendVerification(body.Mail); err != nil {
+		h.Logger.Printf("Error mailing the verification link: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error sending the verification mail"})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "If the mail belongs to an unverified account, a verification link was sent to it"})
+}
+
+// sendVerification mails a verification link to the user with the mail unless there is no such unverified user
+func (h *Handler) sendVerification(mail string) error {
+	user, token, err := h.Repo.CreateEmailVerification(mail)
+	if err != nil || user == nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/blogpost/v1/verify-email?token=%s", h.PublicURL, url.QueryEscape(token))
+	return h.Mailer.Send(mailer.Message{
+		To:      user.Mail,
+		Subject: "Verify your blogpost account",
+		Body: fmt.Sprintf("Welcome to blogpost!\n\n"+
+			"Open this link within %v to verify your mail and activate your account:\n\n%s\n\n"+
+			"If you did not sign up you can ignore this mail.\n", h.Config.Accounts.VerificationTTL, link),
+	})
+}
+
+// UnlockUser clears the failed logins that locked an account
+func (h *Handler) UnlockUser(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	body := struct {
+		Mail string `json:"mail" form:"mail" validate:"required,email"`
+	}{}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.Repo.UnlockAccount(principal, body.Mail, c.IP()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "user not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error unlocking the account"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Account unlocked"})
+}
+
+// ResetPassword sets a new password with the token from the reset mail, the token may also be passed as a query parameter
+func (h *Handler) ResetPassword(c *fiber.Ctx) error {
+	body := struct {
+		Token    string `json:"token" form:"token" validate:"required"`
+		Password string `json:"password" form:"password" validate:"required"`
+	}{}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if body.Token == "" {
+		body.Token = c.Query("token")
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.Repo.ResetPassword(body.Token, body.Password); err != nil {
+		if errors.Is(err, repository.ErrInvalidUserToken) || errors.Is(err, passwords.ErrWeakPassword) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error resetting the password"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Password changed, log in again with the new password"})
+}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"blogpost/mailer"
+	"blogpost/middleware"
+	"blogpost/models"
+	"blogpost/repository"
+	"blogpost/utilities"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// pageParams reads the page and per_page query parameters
+func pageParams(c *fiber.Ctx) (int, int) {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	perPage, err := strconv.Atoi(c.Query("per_page", strconv.Itoa(defaultPerPage)))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	return page, perPage
+}
+
+// adminError maps the errors of the user management endpoints to their responses
+func adminError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, repository.ErrUnknownRole):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, repository.ErrSelfSuspension), errors.Is(err, repository.ErrLastAdminRole),
+		errors.Is(err, repository.ErrAlreadySuspended), errors.Is(err, repository.ErrNotSuspended):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error managing the user"})
+}
+
+// ListUsers lists the users a page at a time, filtered by ?q= (part of the mail), ?role= and ?suspended=
+func (h *Handler) ListUsers(c *fiber.Ctx) error {
+	page, perPage := pageParams(c)
+	query := repository.UserQuery{Search: c.Query("q"), Role: c.Query("role"), Page: page, PerPage: perPage}
+
+	if value := c.Query("suspended"); value != "" {
+		suspended, err := strconv.ParseBool(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "suspended must be true or false"})
+		}
+		query.Suspended = &suspended
+	}
+
+	users, total, err := h.Repo.ListUsers(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error listing the users"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"users": users, "total": total, "page": page, "per_page": perPage})
+}
+
+// ChangeUserRole gives a user another role
+func (h *Handler) ChangeUserRole(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	body := struct {
+		Role string `json:"role" form:"role" validate:"required"`
+	}{}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.Repo.ChangeUserRole(principal, c.Params("id"), body.Role, c.IP()); err != nil {
+		return adminError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Role changed, the user has to log in again"})
+}
+
+// SuspendUser blocks a user until they are unsuspended
+func (h *Handler) SuspendUser(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	body := struct {
+		Reason string `json:"reason" form:"reason" validate:"max=255"`
+	}{}
+
+	if err := c.BodyParser(&body); err != nil && !errors.Is(err, fiber.ErrUnprocessableEntity) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.Repo.SuspendUser(principal, c.Params("id"), body.Reason, c.IP()); err != nil {
+		return adminError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User suspended"})
+}
+
+// UnsuspendUser lets a suspended user log in again
+func (h *Handler) UnsuspendUser(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.Repo.UnsuspendUser(principal, c.Params("id"), c.IP()); err != nil {
+		return adminError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User unsuspended"})
+}
+
+// ForceLogout logs a user out of every session
+func (h *Handler) ForceLogout(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.Repo.ForceLogout(principal, c.Params("id"), c.IP()); err != nil {
+		return adminError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User logged out of all sessions"})
+}
+
+// ResetUserCredentials removes a user's password, second factor, sessions and API keys and mails them a password reset link
+func (h *Handler) ResetUserCredentials(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	user, token, err := h.Repo.ResetUserCredentials(principal, c.Params("id"), c.IP())
+	if err != nil {
+		return adminError(c, err)
+	}
+
+	link := fmt.Sprintf("%s/blogpost/v1/password/reset?token=%s", h.PublicURL, url.QueryEscape(token))
+	err = h.Mailer.Send(mailer.Message{
+		To:      user.Mail,
+		Subject: "Your blogpost credentials were reset",
+		Body: fmt.Sprintf("An administrator reset the password and two-factor authentication of your blogpost account.\n\n"+
+			"Send a new password with this token to %s within %v:\n\n%s\n", link, h.Config.Accounts.ResetTTL, token),
+	})
+	if err != nil {
+		h.Logger.Printf("Error mailing the credential reset to the user with ID %v: %v", user.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "the credentials were reset but the mail could not be sent"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Credentials reset, a password reset link was sent to the user"})
+}
+
+// ListAuditLog returns the recorded admin actions newest first, ?user_id= limits them to the actions on one user
+func (h *Handler) ListAuditLog(c *fiber.Ctx) error {
+	page, perPage := pageParams(c)
+
+	entries := []models.AuditLog{}
+	total, err := h.Repo.ListAuditLog(c.Query("user_id"), page, perPage, &entries)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error reading the audit log"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"entries": entries, "total": total, "page": page, "per_page": perPage})
+}
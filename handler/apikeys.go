@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"blogpost/repository"
+	"blogpost/utilities"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateAPIKey creates a key acting as the caller, the key is only shown in this response
+func (h *Handler) CreateAPIKey(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	// a leaked key must not be able to mint more keys
+	if principal.IsAPIKey() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API keys can not manage API keys"})
+	}
+
+	body := struct {
+		Name      string     `json:"name" validate:"required,max=190"`
+		Scopes    []string   `json:"scopes" validate:"required,min=1"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}{}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	key, apiKey, err := h.Repo.CreateAPIKey(principal, body.Name, body.Scopes, body.ExpiresAt)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Store the key now, it is not shown again", "key": key, "api_key": apiKey})
+}
+
+// ListAPIKeys lists the caller's keys without their secrets
+func (h *Handler) ListAPIKeys(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if principal.IsAPIKey() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API keys can not manage API keys"})
+	}
+
+	keys := []models.APIKey{}
+	if err := h.Repo.ListAPIKeys(principal, &keys); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error listing the API keys"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(keys)
+}
+
+// RevokeAPIKey revokes a key immediately
+func (h *Handler) RevokeAPIKey(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if principal.IsAPIKey() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API keys can not manage API keys"})
+	}
+
+	if err := h.Repo.RevokeAPIKey(principal, c.Params("id")); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error revoking the API key"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "API key revoked"})
+}
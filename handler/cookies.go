@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"blogpost/repository"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	accessTokenCookie  = "access_token"
+	refreshTokenCookie = "refresh_token"
+	// the refresh token is only sent to the endpoint that rotates it
+	refreshTokenPath = "/blogpost/v1/token"
+)
+
+func setTokenCookies(c *fiber.Ctx, tokens *repository.TokenPair) {
+	c.Cookie(&fiber.Cookie{
+		Name:     accessTokenCookie,
+		Value:    tokens.AccessToken,
+		Expires:  tokens.ExpiresAt,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	c.Cookie(&fiber.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    tokens.RefreshToken,
+		Path:     refreshTokenPath,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+}
+
+func clearTokenCookies(c *fiber.Ctx) {
+	expired := time.Unix(0, 0)
+
+	c.Cookie(&fiber.Cookie{Name: accessTokenCookie, Expires: expired, HTTPOnly: true})
+	c.Cookie(&fiber.Cookie{Name: refreshTokenCookie, Path: refreshTokenPath, Expires: expired, HTTPOnly: true})
+}
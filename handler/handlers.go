@@ -1,50 +1,67 @@
 package handler
 
 import (
+	"blogpost/config"
+	"blogpost/mailer"
+	"blogpost/middleware"
 	"blogpost/models"
+	"blogpost/rbac"
 	"blogpost/repository"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type Handler struct {
 	Repo   repository.Operations
 	Logger *log.Logger
+	Keys   *middleware.KeySet
+	Mailer mailer.Mailer
+	Config *config.Config
+	// PublicURL is the base of the links in the mails, taken from the configuration rather than the Host header
+	PublicURL string
 }
 
-func Newhandler(db *repository.DbConnection) *Handler {
-	return &Handler{Repo: db, Logger: db.Logger}
+func Newhandler(db *repository.DbConnection, mail mailer.Mailer) *Handler {
+	return &Handler{Repo: db, Logger: db.Logger, Keys: db.Keys, Mailer: mail, Config: db.Config, PublicURL: db.Config.Server.PublicURL}
 }
 
 // ------------------------------------------------------------USER---------------------------------------------------------------
 func (h *Handler) AddUser(c *fiber.Ctx) error {
-	user := models.User{}
-
-	// parse requestbody, attach to Post struct
-	if err := c.BodyParser(&user); err != nil {
+	// only these fields come from the request, the service keeps the verification, pending mail and suspension
+	signup := struct {
+		Mail     string `json:"mail" form:"mail"`
+		Password string `json:"password" form:"password"`
+		Role     string `json:"role" form:"role"`
+	}{}
+
+	// parse requestbody, attach to signup struct
+	if err := c.BodyParser(&signup); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), 10)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	// public signup only creates members, admins come from the admin seed set
+	if signup.Role != "" && signup.Role != rbac.RoleMember {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": fmt.Sprintf("signup can not assign the role %q", signup.Role)})
 	}
 
-	user.Password = string(hash)
+	user := models.User{Role: rbac.RoleMember, Mail: signup.Mail, Password: signup.Password}
 
 	if err := h.Repo.AddUser(&user); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	loginUrl := fmt.Sprintf("localhost:8000/blogpost/v1/login/%s", user.Mail)
-	fmt.Println(loginUrl)
+	// the account can log in once the mail is verified, a failed mail can be sent again through the resend endpoint
+	if err := h.sendVerification(user.Mail); err != nil {
+		h.Logger.Printf("Error mailing the verification link to the user with ID %v: %v", user.ID, err)
+	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Created User!!! Kindly store/remeber the user id!!!!", "userID": user.ID, "loginURL": loginUrl})
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Created User!!! Kindly verify your mail with the link sent to it before logging in", "userID": user.ID})
 }
 
 // Login
@@ -52,32 +69,104 @@ func (h *Handler) Login(c *fiber.Ctx) error {
 	email := c.FormValue("email")
 	password := c.FormValue("password")
 
-	token, err := h.Repo.Login(email, password)
+	tokens, err := h.Repo.Login(email, password, clientOf(c))
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		var challenge *repository.MFARequiredError
+		if errors.As(err, &challenge) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error(), "mfa_required": true, "mfa_token": challenge.Token})
+		}
+		return loginError(c, err)
 	}
 
-	c.Cookie(&fiber.Cookie{
-		Name:  "access_token",
-		Value: token,
-	})
+	setTokenCookies(c, tokens)
 
 	return c.Redirect("/blogpost/v1/search-all-posts", fiber.StatusFound)
 	//return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Loggged in Successfully!!!", "Token": token})
 }
 
-// GetRoleID Handler function
-func (h *Handler) GetRoleID(c *fiber.Ctx) error {
-	var user models.User
+// loginError maps the errors of the login steps to their responses
+func loginError(c *fiber.Ctx, err error) error {
+	var throttled *repository.ThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, repository.ErrInvalidCredentials), errors.Is(err, repository.ErrInvalidMFACode), errors.Is(err, repository.ErrInvalidMFAToken):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, repository.ErrEmailNotVerified), errors.Is(err, middleware.ErrAccountSuspended):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	if err := c.BodyParser(&user); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+}
+
+// RefreshTokens exchanges a refresh token, from the refresh_token cookie or the request body, for a new token pair
+func (h *Handler) RefreshTokens(c *fiber.Ctx) error {
+	body := struct {
+		RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	}{}
+
+	refreshToken := c.Cookies(refreshTokenCookie)
+	if refreshToken == "" {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		refreshToken = body.RefreshToken
 	}
 
-	if err := h.Repo.GetRoleID(&user); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	tokens, err := h.Repo.RefreshTokens(refreshToken, clientOf(c))
+	if err != nil {
+		clearTokenCookies(c)
+		if errors.Is(err, repository.ErrInvalidRefreshToken) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		if errors.Is(err, middleware.ErrAccountSuspended) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"RoleID": user.ID})
+
+	setTokenCookies(c, tokens)
+
+	return c.Status(fiber.StatusOK).JSON(tokens)
+}
+
+// Logout revokes the session of the current access token
+func (h *Handler) Logout(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.Repo.Logout(principal); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	clearTokenCookies(c)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Logged out"})
+}
+
+// LogoutAll revokes every session of the current user
+func (h *Handler) LogoutAll(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.Repo.RevokeAllSessions(principal.UserID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	clearTokenCookies(c)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Logged out of all sessions"})
+}
+
+// JWKS publishes the public keys the access tokens can be verified with
+func (h *Handler) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(h.Keys.JWKS())
 }
 
 // ----------------------------------------------POSTS-----------------------------------------------------------------------
@@ -85,11 +174,19 @@ func (h *Handler) GetRoleID(c *fiber.Ctx) error {
 func (h *Handler) AddPost(c *fiber.Ctx) error {
 	post := models.Post{}
 
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
 	// parse requestbody, attach to Post struct
 	if err := c.BodyParser(&post); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"error": err.Error()}})
 	}
 
+	// the author is always the authenticated admin
+	post.RoleID = principal.UserID
+
 	if err := h.Repo.AddPost(&post); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"error": err.Error()}})
 	}
@@ -97,15 +194,63 @@ func (h *Handler) AddPost(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON("Created post!! Kindly note the post id:" + fmt.Sprint(post.ID))
 }
 
-// Search all the post handler function
+// postStatusRequest is the body of ChangePostStatus
+type postStatusRequest struct {
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// ChangePostStatus drafts, schedules, publishes or archives the post with the ID in the path
+func (h *Handler) ChangePostStatus(c *fiber.Ctx) error {
+	body := postStatusRequest{}
+
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	post, err := h.Repo.ChangePostStatus(principal, c.Params("id"), body.Status, body.PublishAt)
+	switch {
+	case errors.Is(err, repository.ErrPostNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, repository.ErrInvalidTransition):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, repository.ErrInvalidStatus), errors.Is(err, repository.ErrPublishAtRequired):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error changing the status of the post"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Changed the status of the post to " + post.Status, "post": post})
+}
+
+// Search the posts matching q, filtered and ordered by the query string, with the facet counts of all the matches.
+// Without q the data is the posts themselves.
 func (h *Handler) SearchAllPost(c *fiber.Ctx) error {
-	posts := []models.Post{}
+	query, err := postQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	if err := h.Repo.SearchAllPost(&posts); err != nil {
+	results, page, facets, err := h.Repo.ListPosts(query, pageRequest(c))
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(posts)
+	var data interface{} = results
+	if query.Text == "" {
+		posts := make([]models.Post, 0, len(results))
+		for _, result := range results {
+			posts = append(posts, result.Post)
+		}
+		data = posts
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": data, "page": page, "facets": facets})
 }
 
 // GetPostID handler function
@@ -132,19 +277,12 @@ func (h *Handler) UpdatePostByID(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"error": err.Error()}})
 	}
 
-	cookie := c.Cookies("access_token")
-	token, err := jwt.Parse(cookie, func(token *jwt.Token) (interface{}, error) {
-		return []byte("secret"), nil
-	})
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"error": err.Error()}})
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	payload := token.Claims.(jwt.MapClaims)
-
-	fmt.Println("Payload", payload)
-
-	post, err := h.Repo.UpdatePostByID(postID, payload["email"].(string), data)
+	post, err := h.Repo.UpdatePostByID(principal, postID, data)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -156,20 +294,12 @@ func (h *Handler) UpdatePostByID(c *fiber.Ctx) error {
 func (h *Handler) DeletePostByID(c *fiber.Ctx) error {
 	post := models.Post{}
 	postID := c.Query("post_id")
-	cookie := c.Cookies("access_token")
-
-	token, err := jwt.Parse(cookie, func(token *jwt.Token) (interface{}, error) {
-		return []byte("secret"), nil
-	})
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"error": err.Error()}})
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	payload := token.Claims.(jwt.MapClaims)
-
-	fmt.Println("Payload", payload)
-
-	err = h.Repo.DeletePostByID(payload["email"].(string), postID, &post)
+	err := h.Repo.DeletePostByID(principal, postID, &post)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -181,21 +311,14 @@ func (h *Handler) DeletePostByID(c *fiber.Ctx) error {
 func (h *Handler) GetPostBasedOnPostID(c *fiber.Ctx) error {
 	post := models.Post{}
 
-	cookie := c.Cookies("access_token")
-
-	token, err := jwt.Parse(cookie, func(token *jwt.Token) (interface{}, error) {
-		return []byte("secret"), nil
-	})
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"error": err.Error()}})
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	payload := token.Claims.(jwt.MapClaims)
-
-	fmt.Println("Payload", payload)
 	postID := c.Query("post_id")
 
-	err = h.Repo.GetPostbasedOnPostID(payload["email"].(string), postID, &post)
+	err := h.Repo.GetPostbasedOnPostID(principal, postID, &post)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -207,37 +330,29 @@ func (h *Handler) GetPostBasedOnPostID(c *fiber.Ctx) error {
 func (h *Handler) GetPostBasedOnRoleID(c *fiber.Ctx) error {
 	post := []models.Post{}
 
-	cookie := c.Cookies("access_token")
-
-	token, err := jwt.Parse(cookie, func(token *jwt.Token) (interface{}, error) {
-		return []byte("secret"), nil
-	})
-
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"error": err.Error()}})
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	payload := token.Claims.(jwt.MapClaims)
-
-	fmt.Println("Payload", payload)
-
-	err = h.Repo.GetPostBasedOnRoleID(payload["email"].(string), &post)
+	page, err := h.Repo.GetPostBasedOnRoleID(principal, pageRequest(c), &post)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Retrived the post Successfully", "post": post})
+	return pageResponse(c, post, page)
 }
 
 // Get post based Category handler function
 func (h *Handler) GetPostBasedOnCategory(c *fiber.Ctx) error {
 	posts := []models.Post{}
 
-	category := c.FormValue("category")
-	if err := h.Repo.GetPostBasedOnCategory(category, &posts); err != nil {
+	category := c.Query("category")
+	page, err := h.Repo.GetPostBasedOnCategory(category, pageRequest(c), &posts)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"Post": posts})
+	return pageResponse(c, posts, page)
 }
 
 // Get post based Category handler function
@@ -271,29 +386,25 @@ func (h *Handler) GetPostStatistics(c *fiber.Ctx) error {
 // ------------------------------------------------Comments--------------------------------------------------------------------
 func (h *Handler) AddComments(c *fiber.Ctx) error {
 	comment := models.Comments{}
-	cookie := c.Cookies("access_token")
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
 
-	token, err := jwt.Parse(cookie, func(token *jwt.Token) (interface{}, error) {
-		return []byte("secret"), nil
-	})
+	postID, err := uuid.Parse(c.Query("post_id"))
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"error": err.Error()}})
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid post_id"})
 	}
 
-	payload := token.Claims.(jwt.MapClaims)
-
-	postID := c.Query("post_id")
-	roleID := payload["id"].(string)
-
-	comment.PostID = uuid.MustParse(postID)
-	comment.RoleID = uuid.MustParse(roleID)
-
 	// parse requestbody, attach to Post struct
 	if err := c.BodyParser(&comment); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	if err := h.Repo.AddComments(payload["email"].(string), &comment); err != nil {
+	comment.PostID = postID
+	comment.RoleID = principal.UserID
+
+	if err := h.Repo.AddComments(principal, &comment); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -303,19 +414,11 @@ func (h *Handler) AddComments(c *fiber.Ctx) error {
 // Update the comments based on comment ID
 func (h *Handler) UpdateCommentByID(c *fiber.Ctx) error {
 	data := make(map[string]interface{})
-	cookie := c.Cookies("access_token")
-
-	token, err := jwt.Parse(cookie, func(token *jwt.Token) (interface{}, error) {
-		return []byte("secret"), nil
-	})
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"error": err.Error()}})
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	payload := token.Claims.(jwt.MapClaims)
-
-	fmt.Println("Payload", payload)
-
 	commentID := c.Query("comment_id")
 
 	// parse requestbody, attach to Post struct
@@ -323,9 +426,7 @@ func (h *Handler) UpdateCommentByID(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	fmt.Println("Data in uodate comment in handler", data)
-
-	if err := h.Repo.UpdateCommentByID(payload["email"].(string), commentID, data); err != nil {
+	if err := h.Repo.UpdateCommentByID(principal, commentID, data); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"Message": "comment updated successfully"})
@@ -334,20 +435,14 @@ func (h *Handler) UpdateCommentByID(c *fiber.Ctx) error {
 // Delete the comments added by the user based on the comment ID
 func (h *Handler) DeleteCommentByID(c *fiber.Ctx) error {
 	comment := models.Comments{}
-	cookie := c.Cookies("access_token")
-
-	token, err := jwt.Parse(cookie, func(token *jwt.Token) (interface{}, error) {
-		return []byte("secret"), nil
-	})
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"error": err.Error()}})
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	payload := token.Claims.(jwt.MapClaims)
-
 	commentID := c.Query("comment_id")
 
-	if err := h.Repo.DeleteCommentByID(payload["email"].(string), commentID, &comment); err != nil {
+	if err := h.Repo.DeleteCommentByID(principal, commentID, &comment); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"Message": "comment deleted successfully"})
@@ -356,21 +451,16 @@ func (h *Handler) DeleteCommentByID(c *fiber.Ctx) error {
 // Get all the comments added by the user
 func (h *Handler) GetCommentsBasedOnUser(c *fiber.Ctx) error {
 	comment := []models.Comments{}
-	cookie := c.Cookies("access_token")
-
-	token, err := jwt.Parse(cookie, func(token *jwt.Token) (interface{}, error) {
-		return []byte("secret"), nil
-	})
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"error": err.Error()}})
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	payload := token.Claims.(jwt.MapClaims)
-
-	if err := h.Repo.GetCommentsBasedOnUser(payload["email"].(string), &comment); err != nil {
+	page, err := h.Repo.GetCommentsBasedOnUser(principal, pageRequest(c), &comment)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"Comments": comment})
+	return pageResponse(c, comment, page)
 }
 
 // GetCommentsBasedOnPostID
@@ -378,8 +468,9 @@ func (h *Handler) GetCommentsBasedOnPostID(c *fiber.Ctx) error {
 	comment := []models.Comments{}
 	postID := c.Query("post_id")
 
-	if err := h.Repo.GetCommentsBasedOnPostID(postID, &comment); err != nil {
+	page, err := h.Repo.GetCommentsBasedOnPostID(postID, pageRequest(c), &comment)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"Comments": comment})
+	return pageResponse(c, comment, page)
 }
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"blogpost/middleware"
+	"blogpost/repository"
+	"blogpost/utilities"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type mfaCode struct {
+	Code string `json:"code" form:"code" validate:"required"`
+}
+
+// LoginMFA is the second step of a login for accounts with two-factor authentication,
+// it takes the mfa_token returned by Login and a TOTP or recovery code
+func (h *Handler) LoginMFA(c *fiber.Ctx) error {
+	body := struct {
+		MFAToken string `json:"mfa_token" form:"mfa_token" validate:"required"`
+		Code     string `json:"code" form:"code" validate:"required"`
+	}{}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	tokens, err := h.Repo.LoginMFA(body.MFAToken, body.Code, clientOf(c))
+	if err != nil {
+		return loginError(c, err)
+	}
+
+	setTokenCookies(c, tokens)
+
+	return c.Status(fiber.StatusOK).JSON(tokens)
+}
+
+// EnrollTOTP returns a new TOTP secret and its otpauth URI for the authenticator app
+func (h *Handler) EnrollTOTP(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	secret, uri, err := h.Repo.EnrollTOTP(principal)
+	if err != nil {
+		if errors.Is(err, repository.ErrMFAAlreadyEnabled) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error enrolling in two-factor authentication"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"secret": secret, "otpauth_uri": uri, "message": "Add the secret to your authenticator app, then confirm with a code"})
+}
+
+// ConfirmTOTP enables two-factor authentication with a first code and returns the recovery codes
+// along with new tokens for a session started with the second factor
+func (h *Handler) ConfirmTOTP(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	body := mfaCode{}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	codes, tokens, err := h.Repo.ConfirmTOTP(principal, body.Code, clientOf(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidMFACode), errors.Is(err, repository.ErrMFANotEnrolled):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, repository.ErrMFAAlreadyEnabled):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error enabling two-factor authentication"})
+	}
+
+	setTokenCookies(c, tokens)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":        "Two-factor authentication enabled. Store the recovery codes, they are not shown again",
+		"recovery_codes": codes,
+		"tokens":         tokens,
+	})
+}
+
+// DisableTOTP turns two-factor authentication off with a TOTP or recovery code
+func (h *Handler) DisableTOTP(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	body := mfaCode{}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.Repo.DisableTOTP(principal, body.Code); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidMFACode), errors.Is(err, repository.ErrMFANotEnabled):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, repository.ErrMFARequiredByRole):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error disabling two-factor authentication"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Two-factor authentication disabled"})
+}
+
+// SetRoleRequires2FA lets admins require two-factor authentication from every holder of a role
+func (h *Handler) SetRoleRequires2FA(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	body := struct {
+		Role     string `json:"role" validate:"required"`
+		Required *bool  `json:"required" validate:"required"`
+	}{}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.Repo.SetRoleRequires2FA(principal, body.Role, *body.Required, c.IP()); err != nil {
+		if errors.Is(err, repository.ErrUnknownRole) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error changing the two-factor policy"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"role": body.Role, "require_2fa": *body.Required})
+}
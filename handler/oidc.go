@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"blogpost/middleware"
+	"blogpost/repository"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// the state cookie ties the callback to the browser that started the login
+	oidcStateCookie = "oidc_state"
+	oidcStatePath   = "/blogpost/v1/oidc"
+)
+
+// OIDCLogin sends the browser to the identity provider
+func (h *Handler) OIDCLogin(c *fiber.Ctx) error {
+	authURL, state, err := h.Repo.StartOIDCLogin(c.Context())
+	if err != nil {
+		if errors.Is(err, repository.ErrOIDCDisabled) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "the identity provider is not reachable"})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     oidcStatePath,
+		Expires:  time.Now().Add(10 * time.Minute),
+		HTTPOnly: true,
+		// the provider redirects back with a top level GET, which Lax cookies are sent with
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// OIDCCallback completes the login the provider redirected back from and logs the user in like Login does
+func (h *Handler) OIDCCallback(c *fiber.Ctx) error {
+	state := c.Query("state")
+	cookieState := c.Cookies(oidcStateCookie)
+
+	c.Cookie(&fiber.Cookie{Name: oidcStateCookie, Path: oidcStatePath, Expires: time.Unix(0, 0), HTTPOnly: true})
+
+	if providerError := c.Query("error"); providerError != "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "the identity provider refused the login: " + providerError, "description": c.Query("error_description")})
+	}
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": repository.ErrInvalidOIDCState.Error()})
+	}
+
+	tokens, err := h.Repo.FinishOIDCLogin(c.Context(), state, c.Query("code"), clientOf(c))
+	if err != nil {
+		var challenge *repository.MFARequiredError
+		switch {
+		case errors.As(err, &challenge):
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error(), "mfa_required": true, "mfa_token": challenge.Token})
+		case errors.Is(err, repository.ErrOIDCDisabled):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, repository.ErrInvalidOIDCState):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, repository.ErrOIDCEmailNotVerified), errors.Is(err, repository.ErrOIDCNoAccount), errors.Is(err, repository.ErrOIDCIdentityConflict),
+			errors.Is(err, middleware.ErrAccountSuspended):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "error completing the login with the identity provider"})
+	}
+
+	setTokenCookies(c, tokens)
+
+	return c.Redirect("/blogpost/v1/search-all-posts", fiber.StatusFound)
+}
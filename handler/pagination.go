@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"blogpost/repository"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// pageRequest reads the cursor and limit query parameters of a listing, the repository applies the default and maximum limit
+func pageRequest(c *fiber.Ctx) repository.PageRequest {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil {
+		limit = 0
+	}
+
+	return repository.PageRequest{Cursor: c.Query("cursor"), Limit: limit}
+}
+
+// pageResponse is the envelope of every cursor paginated listing
+func pageResponse(c *fiber.Ctx, data interface{}, page repository.PageInfo) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": data, "page": page})
+}
+
+// postQuery reads the search, filters and ordering of the post listing from the query string.
+// Filters taking several values accept the parameter repeated or comma separated.
+func postQuery(c *fiber.Ctx) (repository.PostQuery, error) {
+	q := repository.PostQuery{
+		Text:       c.Query("q"),
+		Categories: queryList(c, "category"),
+		Sort:       c.Query("sort"),
+	}
+
+	switch c.Query("order") {
+	case "", "desc":
+	case "asc":
+		q.Ascending = true
+	default:
+		return q, fmt.Errorf("order must be asc or desc")
+	}
+
+	for _, author := range queryList(c, "author") {
+		id, err := uuid.Parse(author)
+		if err != nil {
+			return q, fmt.Errorf("invalid author %q", author)
+		}
+		q.Authors = append(q.Authors, id)
+	}
+
+	var err error
+	if q.From, err = queryTime(c, "from", false); err != nil {
+		return q, err
+	}
+
+	if q.To, err = queryTime(c, "to", true); err != nil {
+		return q, err
+	}
+
+	if q.MinViews, err = queryCount(c, "min_views"); err != nil {
+		return q, err
+	}
+
+	if q.MinComments, err = queryCount(c, "min_comments"); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+// queryList collects the values of a repeated or comma separated parameter
+func queryList(c *fiber.Ctx, name string) []string {
+	values := []string{}
+	for _, raw := range c.Context().QueryArgs().PeekMulti(name) {
+		for _, value := range strings.Split(string(raw), ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+
+	return values
+}
+
+// queryTime reads an RFC 3339 time or a date. A date as the end of a range includes the whole day.
+func queryTime(c *fiber.Ctx, name string, end bool) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a date like 2024-01-31 or an RFC 3339 time", name)
+	}
+
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return &t, nil
+}
+
+func queryCount(c *fiber.Ctx, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("%s must be a number of at least 0", name)
+	}
+
+	return count, nil
+}
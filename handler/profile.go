@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"blogpost/mailer"
+	"blogpost/middleware"
+	"blogpost/passwords"
+	"blogpost/repository"
+	"blogpost/utilities"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// accountError maps the errors of the self service endpoints to their responses
+func accountError(c *fiber.Ctx, err error) error {
+	var throttled *repository.ThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, repository.ErrWrongPassword), errors.Is(err, repository.ErrLoginNotRecent):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, repository.ErrMailInUse), errors.Is(err, repository.ErrLastAdmin):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, repository.ErrNoPassword), errors.Is(err, repository.ErrNoPasswordGiven), errors.Is(err, repository.ErrSameMail),
+		errors.Is(err, repository.ErrInvalidUserToken), errors.Is(err, passwords.ErrWeakPassword):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error updating the account"})
+}
+
+// Me returns the profile of the caller
+func (h *Handler) Me(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	profile, err := h.Repo.Profile(principal)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error reading the profile"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(profile)
+}
+
+// ChangePassword sets a new password, every other session is logged out
+func (h *Handler) ChangePassword(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if principal.IsAPIKey() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API keys can not change the account"})
+	}
+
+	body := struct {
+		CurrentPassword string `json:"current_password" form:"current_password" validate:"required"`
+		NewPassword     string `json:"new_password" form:"new_password" validate:"required"`
+	}{}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	tokens, err := h.Repo.ChangePassword(principal, body.CurrentPassword, body.NewPassword, clientOf(c))
+	if err != nil {
+		return accountError(c, err)
+	}
+
+	setTokenCookies(c, tokens)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Password changed, your other sessions were logged out", "tokens": tokens})
+}
+
+// ChangeEmail starts a mail change, the new address takes over once the link mailed to it is opened
+func (h *Handler) ChangeEmail(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if principal.IsAPIKey() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API keys can not change the account"})
+	}
+
+	body := struct {
+		Mail            string `json:"mail" form:"mail" validate:"required,email,max=190"`
+		CurrentPassword string `json:"current_password" form:"current_password" validate:"required"`
+	}{}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := utilities.ValidateStruct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	user, token, err := h.Repo.RequestEmailChange(principal, body.Mail, body.CurrentPassword, c.IP())
+	if err != nil {
+		return accountError(c, err)
+	}
+
+	link := fmt.Sprintf("%s/blogpost/v1/email-change/confirm?token=%s", h.PublicURL, url.QueryEscape(token))
+	err = h.Mailer.Send(mailer.Message{
+		To:      user.PendingMail,
+		Subject: "Confirm your new blogpost mail address",
+		Body: fmt.Sprintf("Your blogpost account asked to use this address from now on.\n\n"+
+			"Open this link within %v to confirm it:\n\n%s\n\n"+
+			"If you did not ask for it you can ignore this mail.\n", h.Config.Accounts.VerificationTTL, link),
+	})
+	if err != nil {
+		h.Logger.Printf("Error mailing the mail change confirmation to the user with ID %v: %v", user.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error sending the confirmation mail"})
+	}
+
+	// the current address learns about the change in case the account was taken over
+	err = h.Mailer.Send(mailer.Message{
+		To:      user.Mail,
+		Subject: "Your blogpost mail address is being changed",
+		Body: fmt.Sprintf("Your blogpost account asked to move to %s.\n\n"+
+			"If that was not you, reset your password right away.\n", user.PendingMail),
+	})
+	if err != nil {
+		h.Logger.Printf("Error mailing the mail change notice to the user with ID %v: %v", user.ID, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "A confirmation link was sent to the new address, the current one stays in use until it is opened"})
+}
+
+// ConfirmEmailChange is the link mailed to the new address
+func (h *Handler) ConfirmEmailChange(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "token is required"})
+	}
+
+	if err := h.Repo.ConfirmEmailChange(token); err != nil {
+		return accountError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Mail address changed, log in with the new one from now on"})
+}
+
+// DeleteAccount deletes the caller's account, see DbConnection.DeleteAccount for what happens to their content
+func (h *Handler) DeleteAccount(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if principal.IsAPIKey() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API keys can not change the account"})
+	}
+
+	body := struct {
+		// CurrentPassword is left out by accounts without a password, they log in again through single sign-on
+		CurrentPassword string `json:"current_password" form:"current_password"`
+	}{}
+
+	// a DELETE may come without a body
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	if err := h.Repo.DeleteAccount(principal, body.CurrentPassword, c.IP()); err != nil {
+		return accountError(c, err)
+	}
+
+	clearTokenCookies(c)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Account deleted"})
+}
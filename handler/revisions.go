@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"blogpost/repository"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// revisionError maps the errors of the revision endpoints to their responses
+func revisionError(c *fiber.Ctx, err error, failure string) error {
+	switch {
+	case errors.Is(err, repository.ErrPostNotFound), errors.Is(err, repository.ErrRevisionNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, repository.ErrInvalidCursor):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": failure})
+}
+
+// revisionNumber reads a revision number, 0 when it is not given
+func revisionNumber(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, errors.New("revision numbers start at 1")
+	}
+
+	return number, nil
+}
+
+// ListRevisions lists the revisions of the post with the ID in the path, newest first
+func (h *Handler) ListRevisions(c *fiber.Ctx) error {
+	revisions := []models.PostRevision{}
+
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	page, err := h.Repo.ListRevisions(principal, c.Params("id"), pageRequest(c), &revisions)
+	if err != nil {
+		return revisionError(c, err, "error listing the revisions")
+	}
+
+	return pageResponse(c, revisions, page)
+}
+
+// DiffRevisions compares the revisions ?from= and ?to= of the post, by default the latest one with the one before
+func (h *Handler) DiffRevisions(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	from, err := revisionNumber(c.Query("from"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	to, err := revisionNumber(c.Query("to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	compared, err := h.Repo.DiffRevisions(principal, c.Params("id"), from, to)
+	if err != nil {
+		return revisionError(c, err, "error comparing the revisions")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(compared)
+}
+
+// RestoreRevision puts the content of the revision in the path back into the post, as its newest revision
+func (h *Handler) RestoreRevision(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	number, err := revisionNumber(c.Params("number"))
+	if err != nil || number == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "revision numbers start at 1"})
+	}
+
+	post, revision, err := h.Repo.RestoreRevision(principal, c.Params("id"), number)
+	if err != nil {
+		return revisionError(c, err, "error restoring the revision")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Restored the revision " + strconv.Itoa(number), "post": post, "revision": revision})
+}
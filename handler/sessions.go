@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"blogpost/middleware"
+	"blogpost/repository"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxUserAgent is the longest user agent stored on a session
+const maxUserAgent = 255
+
+// clientOf describes the device of the request for the session it starts
+func clientOf(c *fiber.Ctx) repository.Client {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > maxUserAgent {
+		userAgent = userAgent[:maxUserAgent]
+	}
+
+	return repository.Client{IP: c.IP(), UserAgent: userAgent}
+}
+
+// ListSessions lists where the caller is logged in
+func (h *Handler) ListSessions(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if principal.IsAPIKey() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API keys can not manage sessions"})
+	}
+
+	sessions, err := h.Repo.ListSessions(principal)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error listing the sessions"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(sessions)
+}
+
+// RevokeSession signs one of the caller's sessions out, revoking the current one logs the caller out
+func (h *Handler) RevokeSession(c *fiber.Ctx) error {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if principal.IsAPIKey() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API keys can not manage sessions"})
+	}
+
+	if err := h.Repo.RevokeSession(principal, c.Params("id")); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error revoking the session"})
+	}
+
+	if c.Params("id") == principal.SessionID.String() {
+		clearTokenCookies(c)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Session revoked"})
+}
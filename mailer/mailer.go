@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"blogpost/config"
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"time"
+)
+
+// Message is a plain text mail
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers the mails sent to the users, such as password reset links
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New returns the mailer selected by the configuration
+func New(cfg config.MailConfig, logger *log.Logger) (Mailer, error) {
+	switch cfg.Driver {
+	case "outbox":
+		return NewOutbox(cfg.From, cfg.OutboxDir, logger)
+	case "smtp":
+		return NewSMTP(cfg.From, cfg.SMTP), nil
+	}
+
+	return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+}
+
+// format renders the message with its headers as RFC 5322 text
+func format(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(msg.Body)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outbox writes every mail to a .eml file instead of delivering it, for local development
+type Outbox struct {
+	From   string
+	Dir    string
+	Logger *log.Logger
+}
+
+func NewOutbox(from, dir string, logger *log.Logger) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating the outbox directory: %v", err)
+	}
+
+	return &Outbox{From: from, Dir: dir, Logger: logger}, nil
+}
+
+func (o *Outbox) Send(msg Message) error {
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000"), uuid.NewString()[:8])
+	path := filepath.Join(o.Dir, name)
+
+	if err := os.WriteFile(path, format(o.From, msg), 0600); err != nil {
+		return fmt.Errorf("error writing the mail to the outbox: %v", err)
+	}
+
+	o.Logger.Printf("Mail %q to %v written to %v", msg.Subject, msg.To, path)
+	return nil
+}
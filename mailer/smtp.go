@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"blogpost/config"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTP delivers the mails through a relay, using STARTTLS when the server offers it
+type SMTP struct {
+	From   string
+	Config config.SMTPConfig
+}
+
+func NewSMTP(from string, cfg config.SMTPConfig) *SMTP {
+	return &SMTP{From: from, Config: cfg}
+}
+
+func (s *SMTP) Send(msg Message) error {
+	addr := net.JoinHostPort(s.Config.Host, strconv.Itoa(s.Config.Port))
+
+	var auth smtp.Auth
+	if s.Config.Username != "" {
+		auth = smtp.PlainAuth("", s.Config.Username, s.Config.Password, s.Config.Host)
+	}
+
+	if err := smtp.SendMail(addr, auth, s.From, []string{msg.To}, format(s.From, msg)); err != nil {
+		return fmt.Errorf("error sending the mail: %v", err)
+	}
+
+	return nil
+}
@@ -1,21 +1,31 @@
 package main
 
 import (
-	driver "blogpost/drivers"
-	"blogpost/lookup"
-
-	"blogpost/repository"
-	"blogpost/router"
-	migrators "blogpost/updates"
+	"blogpost/commands"
+	"blogpost/config"
 	"fmt"
 	"io"
 	"log"
 	"os"
 )
 
+const usage = `usage: blogpost [command] [flags]
+
+commands:
+  serve     start the HTTP server (default)
+  migrate   inspect and change the database schema, see "blogpost migrate"
+  seed      insert seed data: blogpost seed [list | categories | admin | demo ...]
+  oidc-mock serve a mock OpenID Connect provider for local testing: blogpost oidc-mock [host:port]`
+
 func main() {
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Println("Error loading configuration:", err)
+		os.Exit(1)
+	}
+
 	// Open or create a log file
-	file, err := os.OpenFile("log.log", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	file, err := os.OpenFile(cfg.Server.LogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		fmt.Println("Error opening log file:", err)
 		return
@@ -25,8 +35,27 @@ func main() {
 	multiWriter := io.MultiWriter(os.Stdout, file)
 	logger := log.New(multiWriter, "Blog-Post ", log.LstdFlags)
 
-	dbConnection := driver.SQLDriver()
-	migrators.Migrations(dbConnection)
-	lookup.LookUp(migrators.NewLookUpDB(dbConnection))
-	router.Routing(repository.NewDbConnection(dbConnection, logger))
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		err = commands.Serve(cfg, logger)
+	case "migrate":
+		err = commands.Migrate(cfg, logger, args)
+	case "seed":
+		err = commands.Seed(cfg, logger, args)
+	case "oidc-mock":
+		err = commands.OIDCMock(logger, args)
+	default:
+		err = fmt.Errorf("unknown command %q\n%s", command, usage)
+	}
+
+	if err != nil {
+		fmt.Println("Error:", err)
+		file.Close()
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"blogpost/rbac"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Principal is the authenticated caller, Authenticate stores it in the request context
+type Principal struct {
+	UserID uuid.UUID
+	Email  string
+	Role   string
+	// SessionID identifies the login the token was issued for, revoking it invalidates the token
+	SessionID uuid.UUID
+	// MFA is set when the login was completed with a second factor
+	MFA bool
+	// APIKeyID is set when the caller authenticated with an API key, which only grants its Scopes
+	APIKeyID uuid.UUID
+	Scopes   []string
+}
+
+// IsAPIKey reports whether the caller authenticated with an API key rather than a login
+func (p Principal) IsAPIKey() bool {
+	return p.APIKeyID != uuid.Nil
+}
+
+// Allows reports whether the credentials may use the permission, the permission must still be granted to the user.
+// Logins may use every permission of the user, API keys only those in their scopes.
+func (p Principal) Allows(permission string) bool {
+	return !p.IsAPIKey() || rbac.Has(p.Scopes, permission)
+}
+
+// SessionValidator rejects tokens whose session was revoked on the server
+type SessionValidator interface {
+	ValidateSession(principal Principal) error
+}
+
+// APIKeyValidator authenticates the API keys sent as Bearer tokens
+type APIKeyValidator interface {
+	AuthenticateAPIKey(key, ip string) (Principal, error)
+}
+
+// ErrAccountSuspended is returned by the validators for the accounts an admin has suspended
+var ErrAccountSuspended = errors.New("the account is suspended")
+
+// APIKeyPrefix starts every API key, it tells them apart from access tokens
+const APIKeyPrefix = "bp_"
+
+const principalKey = "principal"
+
+// Authenticate validates the access token sent either as a Bearer Authorization header or as the
+// access_token cookie, checks its session is still active, and stores the resulting Principal in
+// c.Locals for the following handlers. A Bearer API key is accepted in place of the access token.
+func Authenticate(keys *KeySet, sessions SessionValidator, apiKeys APIKeyValidator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenString := ExtractTokenFromHeader(c)
+		if strings.HasPrefix(tokenString, APIKeyPrefix) {
+			principal, err := apiKeys.AuthenticateAPIKey(tokenString, c.IP())
+			if err != nil {
+				return unauthenticated(c, err)
+			}
+
+			c.Locals(principalKey, principal)
+			return c.Next()
+		}
+
+		if tokenString == "" {
+			tokenString = c.Cookies("access_token")
+		}
+
+		if tokenString == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+
+		principal, err := ParseToken(keys, tokenString)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+
+		if err := sessions.ValidateSession(principal); err != nil {
+			return unauthenticated(c, err)
+		}
+
+		c.Locals(principalKey, principal)
+		return c.Next()
+	}
+}
+
+// unauthenticated answers a rejected credential, suspended accounts are told why
+func unauthenticated(c *fiber.Ctx, err error) error {
+	if errors.Is(err, ErrAccountSuspended) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+}
+
+// CurrentPrincipal returns the Principal stored by Authenticate
+func CurrentPrincipal(c *fiber.Ctx) (Principal, bool) {
+	principal, ok := c.Locals(principalKey).(Principal)
+	return principal, ok
+}
+
+// ParseToken verifies the signature, signing method and expiry of the token and extracts its claims.
+// Only the algorithms of the configured keys are accepted, and each key only with its own algorithm.
+func ParseToken(keys *KeySet, tokenString string) (Principal, error) {
+	// tokens without an expiry are not accepted
+	token, err := jwt.Parse(tokenString, keys.Keyfunc, jwt.WithValidMethods(keys.Methods()), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return Principal{}, errors.New("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return Principal{}, errors.New("invalid token claims")
+	}
+
+	id, _ := claims["id"].(string)
+	email, _ := claims["email"].(string)
+	role, _ := claims["role"].(string)
+	sid, _ := claims["sid"].(string)
+	mfa, _ := claims["mfa"].(bool)
+
+	userID, err := uuid.Parse(id)
+	if err != nil || email == "" || role == "" {
+		return Principal{}, errors.New("invalid token claims")
+	}
+
+	// tokens issued before sessions existed can not be revoked and are no longer accepted
+	sessionID, err := uuid.Parse(sid)
+	if err != nil {
+		return Principal{}, errors.New("invalid token session")
+	}
+
+	return Principal{UserID: userID, Email: email, Role: role, SessionID: sessionID, MFA: mfa}, nil
+}
@@ -1,66 +1,73 @@
 package middleware
 
 import (
-	"net/http"
+	"blogpost/rbac"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 )
 
-func AdminAuthorize(jwtSecret []byte, next fiber.Handler) fiber.Handler {
+// PermissionResolver looks up the permissions granted to a user through their roles
+type PermissionResolver interface {
+	Permissions(userID uuid.UUID) ([]string, error)
+}
+
+// MFAPolicy reports whether any role of a user requires two-factor authentication
+type MFAPolicy interface {
+	RequiresMFA(userID uuid.UUID) (bool, error)
+}
+
+// RequireMFA keeps callers whose role requires two-factor authentication out unless they logged in with a second factor
+func RequireMFA(policy MFAPolicy) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		tokenString := ExtractTokenFromHeader(c)
-		if tokenString == "" {
-			return fiber.NewError(http.StatusUnauthorized, "Unauthorized")
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-		if err != nil || !token.Valid {
-			return fiber.NewError(http.StatusUnauthorized, "Unauthorized Admin")
+		// API keys can only be created from a session that satisfied the policy
+		if principal.MFA || principal.IsAPIKey() {
+			return c.Next()
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		required, err := policy.RequiresMFA(principal.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error checking the two-factor policy"})
 		}
 
-		isAdmin, ok := claims["role"].(string)
-		if !ok || strings.ToLower(isAdmin) != "admin" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "UnAuthorized Admin"})
+		if required {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "two-factor authentication is required for your role, enroll at /blogpost/v1/2fa/enroll and log in again"})
 		}
 
-		return next(c)
+		return c.Next()
 	}
 }
 
-func MemberAuthorize(jwtSecret []byte, next fiber.Handler) fiber.Handler {
+// RequirePermission only lets through callers authenticated by Authenticate that hold every given permission
+func RequirePermission(resolver PermissionResolver, permissions ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		tokenString := ExtractTokenFromHeader(c)
-		if tokenString == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "UnAuthorized Member"})
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-		if err != nil || !token.Valid {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "UnAuthorized Member"})
+		granted, err := resolver.Permissions(principal.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "error checking the permissions"})
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			return fiber.NewError(http.StatusInternalServerError, err.Error())
-		}
+		for _, permission := range permissions {
+			if !rbac.Has(granted, permission) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "missing permission " + permission})
+			}
 
-		isAdmin, ok := claims["role"].(string)
-		if !ok || strings.ToLower(isAdmin) != "member" {
-			return fiber.NewError(http.StatusUnauthorized, "Unauthorized")
+			if !principal.Allows(permission) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "the API key is not scoped for " + permission})
+			}
 		}
 
-		return next(c)
+		return c.Next()
 	}
 }
 
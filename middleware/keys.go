@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"blogpost/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeySet signs the access tokens with the active key and verifies them with every key still accepted.
+// With asymmetric keys the tokens carry the kid of their key so previous keys can be kept during a rotation.
+// Without a signing key the tokens fall back to HS256 with the shared secret.
+type KeySet struct {
+	signing  *key
+	verifier map[string]*key
+}
+
+type key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// Private is only set on the signing key
+	Private crypto.PrivateKey
+	// Public is the verification key, the secret itself for HS256
+	Public interface{}
+}
+
+// JWK is the public part of a verification key as published in the JWKS document
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// hmacKeyID is not published, it only lets the HS256 key live in the same lookup as the others
+const hmacKeyID = ""
+
+// NewKeySet loads the signing and verification keys from the PEM files named in the configuration
+func NewKeySet(cfg config.JWTConfig) (*KeySet, error) {
+	ks := &KeySet{verifier: map[string]*key{}}
+
+	if cfg.SigningKey == "" {
+		ks.signing = &key{ID: hmacKeyID, Method: jwt.SigningMethodHS256, Private: []byte(cfg.Secret), Public: []byte(cfg.Secret)}
+		ks.verifier[hmacKeyID] = ks.signing
+
+		if len(cfg.VerificationKeys) > 0 {
+			return nil, errors.New("verification keys require a signing key, HS256 tokens are only verified with the secret")
+		}
+		return ks, nil
+	}
+
+	signing, err := loadKey(cfg.SigningKey, true)
+	if err != nil {
+		return nil, err
+	}
+	ks.signing = signing
+	ks.verifier[signing.ID] = signing
+
+	for _, path := range cfg.VerificationKeys {
+		verification, err := loadKey(path, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := ks.verifier[verification.ID]; ok {
+			continue
+		}
+		ks.verifier[verification.ID] = verification
+	}
+
+	return ks, nil
+}
+
+// Sign signs the claims with the active key and sets its kid header
+func (ks *KeySet) Sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	if ks.signing.ID != hmacKeyID {
+		token.Header["kid"] = ks.signing.ID
+	}
+
+	return token.SignedString(ks.signing.Private)
+}
+
+// Methods returns the algorithms of the accepted keys, tokens signed with any other algorithm are rejected
+func (ks *KeySet) Methods() []string {
+	methods := []string{}
+	seen := map[string]bool{}
+	for _, k := range ks.verifier {
+		if !seen[k.Method.Alg()] {
+			seen[k.Method.Alg()] = true
+			methods = append(methods, k.Method.Alg())
+		}
+	}
+
+	return methods
+}
+
+// Keyfunc picks the verification key named by the kid header and requires the token to use that key's algorithm
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	k, ok := ks.verifier[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if token.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", token.Header["alg"], kid)
+	}
+
+	return k.Public, nil
+}
+
+// JWKS returns the public verification keys, it is empty when the tokens are signed with the shared secret
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, k := range ks.verifier {
+		if k.ID == hmacKeyID {
+			continue
+		}
+
+		jwk := publicJWK(k.Public)
+		jwk.Use = "sig"
+		jwk.Algorithm = k.Method.Alg()
+		jwk.KeyID = k.ID
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+// loadKey reads an RSA or Ed25519 key from a PEM file, a private key is required for signing
+func loadKey(path string, private bool) (*key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key file: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%v is not a PEM file", path)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%v: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing key file %v: %v", path, err)
+	}
+
+	k := &key{}
+	switch parsedKey := parsed.(type) {
+	case *rsa.PrivateKey:
+		k.Method, k.Private, k.Public = jwt.SigningMethodRS256, parsedKey, &parsedKey.PublicKey
+	case *rsa.PublicKey:
+		k.Method, k.Public = jwt.SigningMethodRS256, parsedKey
+	case ed25519.PrivateKey:
+		k.Method, k.Private, k.Public = jwt.SigningMethodEdDSA, parsedKey, parsedKey.Public()
+	case ed25519.PublicKey:
+		k.Method, k.Public = jwt.SigningMethodEdDSA, parsedKey
+	default:
+		return nil, fmt.Errorf("%v: only RSA and Ed25519 keys are supported", path)
+	}
+
+	if private && k.Private == nil {
+		return nil, fmt.Errorf("%v: the signing key must be a private key", path)
+	}
+
+	if rsaKey, ok := k.Public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("%v: RSA keys must be at least 2048 bits", path)
+	}
+
+	k.ID = thumbprint(publicJWK(k.Public))
+	return k, nil
+}
+
+// publicJWK returns the key type specific members of the JWK
+func publicJWK(public interface{}) JWK {
+	switch publicKey := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType: "RSA",
+			N:       base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return JWK{KeyType: "OKP", Curve: "Ed25519", X: base64.RawURLEncoding.EncodeToString(publicKey)}
+	}
+
+	return JWK{}
+}
+
+// thumbprint is the RFC 7638 thumbprint of the key, used as its kid
+func thumbprint(jwk JWK) string {
+	var members interface{}
+	if jwk.KeyType == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+
+	canonical, _ := json.Marshal(members)
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
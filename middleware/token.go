@@ -3,40 +3,19 @@ package middleware
 import (
 	"time"
 
-	jwt "github.com/dgrijalva/jwt-go"
-	"github.com/google/uuid"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-const (
-	JwtExpHour = 0
-	JwtExpMin  = 10
-	JwtExpSec  = 30
-)
-
-// MemberToken fubnction is used to generate a new token for the members
-func MemberToken(email string, id uuid.UUID) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"role":  "member",
-		"id":    id,
-		"email": email,
-		"exp":   time.Now().Local().Add(time.Hour*time.Duration(JwtExpHour) + time.Minute*time.Duration(JwtExpMin) + time.Second*time.Duration(JwtExpSec)).Unix(),
+// AccessToken signs a short lived token for the principal, bound to the session (refresh token family) it was issued for
+func AccessToken(keys *KeySet, principal Principal, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return keys.Sign(jwt.MapClaims{
+		"role":  principal.Role,
+		"id":    principal.UserID,
+		"email": principal.Email,
+		"sid":   principal.SessionID,
+		"mfa":   principal.MFA,
+		"iat":   now.Unix(),
+		"exp":   now.Add(ttl).Unix(),
 	})
-
-	// Sign and get the complete encoded token as a string using the secret
-	sToken, err := token.SignedString([]byte("secret"))
-	if err != nil {
-		return "", err
-	}
-
-	return sToken, nil
-}
-
-func AdminToken(email string, id uuid.UUID) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": id, "email": email, "role": "admin", "exp": time.Now().Add(time.Hour * 24 * 30).Unix()})
-
-	tokenString, err := token.SignedString([]byte("secret"))
-	if err != nil {
-		return "", err
-	}
-	return tokenString, nil
 }
@@ -7,51 +7,233 @@ import (
 )
 
 type User struct {
-	ID       uuid.UUID `json:"id" gorm:"type:char(190);primaryKey column:id"`
+	ID       uuid.UUID `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
 	Role     string    `json:"role" gorm:"column:role" validate:"required"`
-	Mail     string    `json:"mail" gorm:"unique;column:mail" validate:"required,email" `
+	Mail     string    `json:"mail" gorm:"unique;size:190;column:mail" validate:"required,email" `
 	Password string    `json:"password" gorm:"column:password" validate:"required"`
+	// EmailVerifiedAt is nil until the user opens the verification link, unverified users can not log in
+	EmailVerifiedAt *time.Time `json:"email_verified_at" gorm:"column:email_verified_at"`
+	// PendingMail is the address a mail change waits to be confirmed for, Mail stays in use until then
+	PendingMail string `json:"pending_mail" gorm:"size:190;column:pending_mail"`
+	// TOTPSecret is set on enrollment, two-factor authentication is only enforced once TOTPEnabledAt is set.
+	// TOTPLastStep is the time step of the last accepted code, a code is never accepted twice.
+	TOTPSecret    string     `json:"-" gorm:"size:64;column:totp_secret"`
+	TOTPEnabledAt *time.Time `json:"-" gorm:"column:totp_enabled_at"`
+	TOTPLastStep  int64      `json:"-" gorm:"default:0;column:totp_last_step"`
+	// SuspendedAt is set while an admin has suspended the account, it can not log in or use its sessions and API keys
+	SuspendedAt     *time.Time `json:"suspended_at" gorm:"column:suspended_at"`
+	SuspendedReason string     `json:"suspended_reason" gorm:"size:255;column:suspended_reason"`
 }
 
+// the states of a post, only published posts are shown to the readers
+const (
+	PostDraft     = "draft"
+	PostScheduled = "scheduled"
+	PostPublished = "published"
+	PostArchived  = "archived"
+)
+
 type Post struct {
-	ID           uuid.UUID `json:"id" gorm:"type:char(190);primaryKey;column:id"`
-	RoleID       uuid.UUID `json:"role_id" gorm:"type:char(190);;column:role_id"`
+	ID           uuid.UUID `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	RoleID       uuid.UUID `json:"role_id" gorm:"type:varchar(36);column:role_id"`
 	Category     string    `json:"category" gorm:"column:category" validate:"required"`
-	Title        string    `json:"title" gorm:"unique;column:title" validate:"required"`
+	Title        string    `json:"title" gorm:"unique;size:190;column:title" validate:"required"`
 	Description  string    `json:"description" gorm:"column:description"`
 	PostDate     time.Time `json:"post_date" gorm:"column:post_date"`
 	CommentCount uint      `json:"comment_count" gorm:"column:comment_count"`
 	ViewsCount   int       `json:"views_count" gorm:"column:views_count"`
 	UserCount    int       `json:"user_count" gorm:"column:user_count"`
-	User         User      `json:"-" gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	// Status is one of the post states, a scheduled post is published by the service once PublishAt has passed
+	Status    string     `json:"status" gorm:"size:16;default:published;index:idx_posts_status;column:status"`
+	PublishAt *time.Time `json:"publish_at" gorm:"column:publish_at"`
+	User      User       `json:"-" gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 }
 
 type Comments struct {
-	ID       uuid.UUID `json:"id" gorm:"type:char(190);primaryKey column:id"`
-	PostID   uuid.UUID `json:"post_id" gorm:"type:char(190); column:post_id"`
-	RoleID   uuid.UUID `json:"role_id" gorm:"type:char(190); column:role_id"`
-	Feedback string    `json:"feedback" gorm:"primaryKey column:feedback" validate:"required"`
-	User     User      `json:"-" gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
-	Post     Post      `json:"-" gorm:"foreignKey:PostID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	ID        uuid.UUID `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	PostID    uuid.UUID `json:"post_id" gorm:"type:varchar(36);column:post_id"`
+	RoleID    uuid.UUID `json:"role_id" gorm:"type:varchar(36);column:role_id"`
+	Feedback  string    `json:"feedback" gorm:"column:feedback" validate:"required"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+	User      User      `json:"-" gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Post      Post      `json:"-" gorm:"foreignKey:PostID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 }
 
 type Views struct {
-	ID       uuid.UUID `json:"id" gorm:"type:char(190);column:id"`
+	ID       uuid.UUID `json:"id" gorm:"type:varchar(36);column:id"`
 	Views    int       `json:"views" gorm:"column:views"`
-	RoleID   uuid.UUID `json:"role_id" gorm:"type:char(190); column:role_id"`
-	PostID   uuid.UUID `json:"post_id" gorm:"type:char(190); column:post_id"`
+	RoleID   uuid.UUID `json:"role_id" gorm:"type:varchar(36);column:role_id"`
+	PostID   uuid.UUID `json:"post_id" gorm:"type:varchar(36);column:post_id"`
 	IsViewed bool      `json:"-" gorm:"column:is_valid"`
 	Post     Post      `json:"-" gorm:"foreignKey:PostID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 	User     User      `json:"-" gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 }
 
 type LookUp struct {
-	ID      int    `json:"id" gorm:"column:id"`
-	Name    string `gorm:"unique;column:name"`
-	Version int    `gorm:"unique;column:version"`
+	ID        int        `json:"id" gorm:"primaryKey;autoIncrement;column:id"`
+	Name      string     `gorm:"unique;size:190;column:name"`
+	Version   int        `gorm:"unique;column:version"`
+	Checksum  string     `gorm:"size:64;column:checksum"`
+	AppliedAt *time.Time `gorm:"column:applied_at"`
+	Dirty     bool       `gorm:"column:dirty"`
+}
+
+// MigrationLock holds a single row while an instance is migrating the database
+type MigrationLock struct {
+	ID        int       `gorm:"primaryKey;autoIncrement:false;column:id"`
+	Owner     string    `gorm:"size:190;column:owner"`
+	LockedAt  time.Time `gorm:"column:locked_at"`
+	ExpiresAt time.Time `gorm:"column:expires_at"`
 }
 
 type Category struct {
-	ID   uuid.UUID `json:"id" gorm:"type:char(190);column:id"`
-	Name string    `json:"name" gorm:"name"`
+	ID   uuid.UUID `json:"id" gorm:"type:varchar(36);column:id"`
+	Name string    `json:"name" gorm:"column:name"`
+}
+
+type Role struct {
+	ID          uuid.UUID    `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	Name        string       `json:"name" gorm:"unique;size:64;column:name"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	// Require2FA keeps the holders of the role out of the protected routes until they log in with a second factor
+	Require2FA bool `json:"require_2fa" gorm:"default:false;column:require_2fa"`
+}
+
+type Permission struct {
+	ID   uuid.UUID `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	Name string    `json:"name" gorm:"unique;size:64;column:name"`
+}
+
+// UserRole assigns a role to a user, a user can hold several roles
+type UserRole struct {
+	UserID uuid.UUID `json:"user_id" gorm:"type:varchar(36);primaryKey;column:user_id"`
+	RoleID uuid.UUID `json:"role_id" gorm:"type:varchar(36);primaryKey;column:role_id"`
+	User   User      `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Role   Role      `json:"-" gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// RefreshToken is stored hashed, every rotation adds a token to the family started by the login.
+// The family ID is the ID of the login's Session.
+type RefreshToken struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	UserID       uuid.UUID  `json:"user_id" gorm:"type:varchar(36);index;column:user_id"`
+	FamilyID     uuid.UUID  `json:"family_id" gorm:"type:varchar(36);index;column:family_id"`
+	TokenHash    string     `json:"-" gorm:"unique;size:64;column:token_hash"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"column:created_at"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"column:expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at" gorm:"column:revoked_at"`
+	ReplacedByID *uuid.UUID `json:"-" gorm:"type:varchar(36);column:replaced_by_id"`
+	// MFA is set on the families started with a second factor, their access tokens carry the mfa claim
+	MFA  bool `json:"mfa" gorm:"default:false;column:mfa"`
+	User User `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// UserToken is a single use token mailed to a user, such as a password reset link. Only its hash is stored.
+type UserToken struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:varchar(36);index;column:user_id"`
+	Purpose   string     `json:"purpose" gorm:"size:32;column:purpose"`
+	TokenHash string     `json:"-" gorm:"unique;size:64;column:token_hash"`
+	CreatedAt time.Time  `json:"created_at" gorm:"column:created_at"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"column:expires_at"`
+	UsedAt    *time.Time `json:"used_at" gorm:"column:used_at"`
+	User      User       `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// LoginThrottle counts the consecutive failed logins of an account ("mail:<mail>") or a client address ("ip:<address>")
+type LoginThrottle struct {
+	Key           string     `json:"key" gorm:"primaryKey;size:190;column:throttle_key"`
+	Failures      int        `json:"failures" gorm:"column:failures"`
+	LastFailureAt time.Time  `json:"last_failure_at" gorm:"column:last_failure_at"`
+	BlockedUntil  *time.Time `json:"blocked_until" gorm:"column:blocked_until"`
+}
+
+// RecoveryCode lets a user log in without the authenticator app, each code works once. Only its hash is stored.
+type RecoveryCode struct {
+	ID       uuid.UUID  `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	UserID   uuid.UUID  `json:"user_id" gorm:"type:varchar(36);index;column:user_id"`
+	CodeHash string     `json:"-" gorm:"unique;size:64;column:code_hash"`
+	UsedAt   *time.Time `json:"used_at" gorm:"column:used_at"`
+	User     User       `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// APIKey authenticates automation as its owner, limited to its scopes. The key is shown once as
+// bp_<prefix>_<secret>, the prefix identifies it and only the hash of the secret is stored.
+type APIKey struct {
+	ID         uuid.UUID `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	UserID     uuid.UUID `json:"user_id" gorm:"type:varchar(36);index;column:user_id"`
+	Name       string    `json:"name" gorm:"size:190;column:name"`
+	Prefix     string    `json:"prefix" gorm:"unique;size:16;column:prefix"`
+	SecretHash string    `json:"-" gorm:"size:64;column:secret_hash"`
+	// Scopes is the comma separated list of the permissions the key may use
+	Scopes     string     `json:"scopes" gorm:"column:scopes"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"column:created_at"`
+	ExpiresAt  *time.Time `json:"expires_at" gorm:"column:expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" gorm:"column:last_used_at"`
+	LastUsedIP string     `json:"last_used_ip" gorm:"size:64;column:last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at" gorm:"column:revoked_at"`
+	User       User       `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// OIDCLogin is a login started at the OpenID Connect provider, it is deleted when the provider redirects back.
+// Only the hash of the state is stored, the nonce and the PKCE verifier never leave the server.
+type OIDCLogin struct {
+	ID           uuid.UUID `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	StateHash    string    `json:"-" gorm:"unique;size:64;column:state_hash"`
+	Nonce        string    `json:"-" gorm:"size:64;column:nonce"`
+	CodeVerifier string    `json:"-" gorm:"size:128;column:code_verifier"`
+	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index;column:expires_at"`
+}
+
+// UserIdentity links a user to an account at an OpenID Connect provider
+type UserIdentity struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	UserID      uuid.UUID  `json:"user_id" gorm:"type:varchar(36);index;column:user_id"`
+	Issuer      string     `json:"issuer" gorm:"size:190;uniqueIndex:idx_identity_subject;column:issuer"`
+	Subject     string     `json:"subject" gorm:"size:190;uniqueIndex:idx_identity_subject;column:subject"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at"`
+	LastLoginAt *time.Time `json:"last_login_at" gorm:"column:last_login_at"`
+	User        User       `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// AuditLog records an administrative action, Details holds its parameters as JSON
+type AuditLog struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	ActorID   uuid.UUID  `json:"actor_id" gorm:"type:varchar(36);index;column:actor_id"`
+	Action    string     `json:"action" gorm:"size:64;index;column:action"`
+	TargetID  *uuid.UUID `json:"target_id" gorm:"type:varchar(36);index;column:target_id"`
+	Details   string     `json:"details" gorm:"column:details"`
+	IP        string     `json:"ip" gorm:"size:64;column:ip"`
+	CreatedAt time.Time  `json:"created_at" gorm:"index;column:created_at"`
+}
+
+// Session is a login on a device. Its refresh tokens form the family with the same ID and its access tokens
+// carry the ID as their sid claim, so revoking the session invalidates both.
+type Session struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:varchar(36);index;column:user_id"`
+	UserAgent  string     `json:"user_agent" gorm:"size:255;column:user_agent"`
+	IP         string     `json:"ip" gorm:"size:64;column:ip"`
+	MFA        bool       `json:"mfa" gorm:"default:false;column:mfa"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"column:created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at" gorm:"column:last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"column:expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at" gorm:"column:revoked_at"`
+	User       User       `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// PostRevision is the content of a post after one of its edits, revisions are only ever added. Number counts the
+// revisions of the post from 1, RestoredFrom is the number of the revision an edit restored.
+type PostRevision struct {
+	ID           uuid.UUID `json:"id" gorm:"type:varchar(36);primaryKey;column:id"`
+	PostID       uuid.UUID `json:"post_id" gorm:"type:varchar(36);uniqueIndex:idx_post_revisions_number,priority:1;column:post_id"`
+	Number       int       `json:"number" gorm:"uniqueIndex:idx_post_revisions_number,priority:2;column:number"`
+	AuthorID     uuid.UUID `json:"author_id" gorm:"type:varchar(36);index;column:author_id"`
+	Category     string    `json:"category" gorm:"column:category"`
+	Title        string    `json:"title" gorm:"size:190;column:title"`
+	Description  string    `json:"description" gorm:"column:description"`
+	RestoredFrom *int      `json:"restored_from" gorm:"column:restored_from"`
+	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at"`
+	Post         Post      `json:"-" gorm:"foreignKey:PostID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
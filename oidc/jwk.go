@@ -0,0 +1,98 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+type jwk struct {
+	KeyType string `json:"kty"`
+	Use     string `json:"use"`
+	KeyID   string `json:"kid"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+// parseJWK returns the kid and public key of a signing key from a JWKS document
+func parseJWK(raw json.RawMessage) (string, interface{}, error) {
+	key := jwk{}
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return "", nil, err
+	}
+
+	if key.Use != "" && key.Use != "sig" {
+		return "", nil, fmt.Errorf("key %q is not a signing key", key.KeyID)
+	}
+
+	switch key.KeyType {
+	case "RSA":
+		n, err := decodeInt(key.N)
+		if err != nil {
+			return "", nil, err
+		}
+		e, err := decodeInt(key.E)
+		if err != nil {
+			return "", nil, err
+		}
+		if !e.IsInt64() {
+			return "", nil, errors.New("RSA exponent too large")
+		}
+		return key.KeyID, &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return "", nil, fmt.Errorf("unsupported curve %q", key.Curve)
+		}
+		x, err := decodeInt(key.X)
+		if err != nil {
+			return "", nil, err
+		}
+		y, err := decodeInt(key.Y)
+		if err != nil {
+			return "", nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return "", nil, errors.New("EC point is not on the curve")
+		}
+		return key.KeyID, &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if key.Curve != "Ed25519" {
+			return "", nil, fmt.Errorf("unsupported curve %q", key.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return "", nil, errors.New("invalid Ed25519 key")
+		}
+		return key.KeyID, ed25519.PublicKey(x), nil
+	}
+
+	return "", nil, fmt.Errorf("unsupported key type %q", key.KeyType)
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+
+	return new(big.Int).SetBytes(raw), nil
+}
@@ -0,0 +1,228 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Mock is a minimal OpenID Connect provider for local development and manual testing.
+// It logs in whoever is entered in its form without a password, it must never be reachable from outside.
+type Mock struct {
+	Issuer string
+
+	key *rsa.PrivateKey
+	kid string
+
+	mu    sync.Mutex
+	codes map[string]mockCode
+}
+
+type mockCode struct {
+	ClientID    string
+	RedirectURI string
+	Challenge   string
+	Nonce       string
+	Email       string
+	Groups      []string
+	MFA         bool
+	ExpiresAt   time.Time
+}
+
+const mockCodeTTL = time.Minute
+
+var mockForm = template.Must(template.New("login").Parse(`<!doctype html>
+<title>Mock identity provider</title>
+<form method="get" action="authorize">
+{{range $name, $values := .}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">{{end}}{{end}}
+<p><label>Email <input name="login_hint" type="email" required></label></p>
+<p><label>Groups <input name="groups" placeholder="comma separated"></label></p>
+<p><label><input name="mfa" type="checkbox" value="true"> Logged in with a second factor</label></p>
+<p><button>Log in</button></p>
+</form>`))
+
+func NewMock(issuer string) (*Mock, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := randomString(8)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mock{Issuer: strings.TrimSuffix(issuer, "/"), key: key, kid: kid, codes: map[string]mockCode{}}, nil
+}
+
+func (m *Mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                m.Issuer,
+			"authorization_endpoint":                m.Issuer + "/authorize",
+			"token_endpoint":                        m.Issuer + "/token",
+			"jwks_uri":                              m.Issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	case "/jwks":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": m.kid,
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	case "/authorize":
+		m.authorize(w, r)
+	case "/token":
+		m.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorize shows the login form, or with a login_hint logs that user in straight away
+func (m *Mock) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "only the code flow with an S256 code challenge is supported", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("login_hint") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = mockForm.Execute(w, query)
+		return
+	}
+
+	code, err := randomString(24)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	groups := []string{}
+	for _, group := range strings.Split(query.Get("groups"), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+
+	m.mu.Lock()
+	m.codes[code] = mockCode{
+		ClientID:    query.Get("client_id"),
+		RedirectURI: redirectURI.String(),
+		Challenge:   query.Get("code_challenge"),
+		Nonce:       query.Get("nonce"),
+		Email:       query.Get("login_hint"),
+		Groups:      groups,
+		MFA:         query.Get("mfa") == "true",
+		ExpiresAt:   time.Now().Add(mockCodeTTL),
+	}
+	m.mu.Unlock()
+
+	callback := redirectURI.Query()
+	callback.Set("code", code)
+	callback.Set("state", query.Get("state"))
+	redirectURI.RawQuery = callback.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token redeems a code once, checking the client, the redirect URI and the PKCE verifier
+func (m *Mock) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	code := r.PostFormValue("code")
+
+	m.mu.Lock()
+	grant, ok := m.codes[code]
+	delete(m.codes, code)
+	m.mu.Unlock()
+
+	clientID := r.PostFormValue("client_id")
+	if user, _, ok := r.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(user)
+	}
+
+	switch {
+	case r.PostFormValue("grant_type") != "authorization_code":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	case !ok || time.Now().After(grant.ExpiresAt):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "unknown or expired code"})
+		return
+	case clientID != grant.ClientID || r.PostFormValue("redirect_uri") != grant.RedirectURI:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "the code was issued to another client"})
+		return
+	case Challenge(r.PostFormValue("code_verifier")) != grant.Challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "code_verifier does not match"})
+		return
+	}
+
+	amr := []string{"pwd"}
+	if grant.MFA {
+		amr = append(amr, "mfa")
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.Issuer,
+		"sub":            "mock|" + grant.Email,
+		"aud":            grant.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          grant.Nonce,
+		"email":          grant.Email,
+		"email_verified": true,
+		"groups":         grant.Groups,
+		"amr":            amr,
+	})
+	idToken.Header["kid"] = m.kid
+
+	signed, err := idToken.SignedString(m.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	accessToken, _ := randomString(24)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
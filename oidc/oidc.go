@@ -0,0 +1,354 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Provider runs the authorization code flow with PKCE against an OpenID Connect provider.
+// The discovery document and the signing keys are fetched on first use and cached.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *Metadata
+	keys     map[string]interface{}
+	// keysFetchedAt limits how often an unknown kid triggers a new fetch of the keys
+	keysFetchedAt time.Time
+}
+
+// Metadata is the part of the discovery document the flow needs
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Identity is what the validated ID token says about the user
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// MFA is set when the provider reports a second factor in the amr claim
+	MFA    bool
+	Claims jwt.MapClaims
+}
+
+var (
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	ErrNonceMismatch  = errors.New("the ID token does not belong to this login")
+)
+
+// keyRefreshInterval is the minimum time between two fetches of the signing keys
+const keyRefreshInterval = time.Minute
+
+// signingMethods are the algorithms accepted on ID tokens, symmetric ones are never accepted
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+func NewProvider(issuer, clientID, clientSecret, redirectURL string, scopes []string) *Provider {
+	return &Provider{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewVerifier returns a random PKCE code verifier, Challenge derives the S256 challenge sent with the authorization request
+func NewVerifier() (string, error) {
+	return randomString(32)
+}
+
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewState returns a random value for the state and nonce parameters
+func NewState() (string, error) {
+	return randomString(32)
+}
+
+func randomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// discover fetches the discovery document once, a failed fetch is retried on the next call
+func (p *Provider) discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	metadata := Metadata{}
+	if err := p.getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("error fetching the discovery document: %v", err)
+	}
+
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("the discovery document is for the issuer %q, expected %q", metadata.Issuer, p.Issuer)
+	}
+
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("the discovery document lacks the authorization, token or jwks endpoint")
+	}
+
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// AuthCodeURL returns the URL the browser is sent to for the login
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code and returns the validated identity from the ID token
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {p.ClientID},
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error calling the token endpoint: %v", err)
+	}
+	defer response.Body.Close()
+
+	body := struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("error reading the token response (status %d): %v", response.StatusCode, err)
+	}
+
+	if response.StatusCode != http.StatusOK || body.Error != "" {
+		return nil, fmt.Errorf("the token endpoint refused the code: %s %s", body.Error, body.ErrorDescription)
+	}
+
+	if body.IDToken == "" {
+		return nil, errors.New("the token response has no ID token")
+	}
+
+	return p.Verify(ctx, body.IDToken, nonce)
+}
+
+// Verify checks the signature, issuer, audience, lifetime and nonce of an ID token
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	// a token issued to several clients names the one it was meant for
+	if audience, _ := claims.GetAudience(); len(audience) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.ClientID {
+			return nil, fmt.Errorf("%w: it was issued to %q", ErrInvalidIDToken, azp)
+		}
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	identity := &Identity{Issuer: p.Issuer, Claims: claims}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: the sub claim is missing", ErrInvalidIDToken)
+	}
+
+	// some providers send the flag as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	for _, method := range ClaimValues(claims, "amr") {
+		if method == "mfa" || method == "otp" || method == "hwk" || method == "swk" {
+			identity.MFA = true
+		}
+	}
+
+	return identity, nil
+}
+
+// ClaimValues returns a string or list of strings claim as a list
+func ClaimValues(claims jwt.MapClaims, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+// key returns the signing key with the kid, the keys are fetched again when the provider has rotated them
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+
+	if time.Since(p.keysFetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if p.metadata == nil {
+		return nil, errors.New("the provider has not been discovered")
+	}
+
+	set := struct {
+		Keys []json.RawMessage `json:"keys"`
+	}{}
+	if err := p.getJSON(ctx, p.metadata.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("error fetching the signing keys: %v", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, raw := range set.Keys {
+		id, key, err := parseJWK(raw)
+		if err != nil {
+			// keys of unsupported types or for encryption are skipped
+			continue
+		}
+		keys[id] = key
+	}
+
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookup finds a key by kid, a token without kid is accepted when the provider has a single key
+func (p *Provider) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, target interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s answered %s", url, response.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(target)
+}
@@ -0,0 +1,131 @@
+package passwords
+
+import (
+	"blogpost/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// Hasher hashes the passwords with the configured algorithm and verifies hashes made with any supported one
+type Hasher struct {
+	cfg config.PasswordConfig
+
+	dummyOnce sync.Once
+	dummy     string
+}
+
+func NewHasher(cfg config.PasswordConfig) *Hasher {
+	return &Hasher{cfg: cfg}
+}
+
+// Hash returns the encoded hash of the password, a PHC string for argon2id and the usual $2a$ form for bcrypt
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == "bcrypt" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.cfg.Argon2Iterations, h.cfg.Argon2Memory, h.cfg.Argon2Parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.cfg.Argon2Memory, h.cfg.Argon2Iterations, h.cfg.Argon2Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether the password matches the encoded hash, and whether the hash should be replaced
+// because it was made with another algorithm or weaker parameters than the configured ones
+func (h *Hasher) Verify(password, encoded string) (ok bool, rehash bool, err error) {
+	if strings.HasPrefix(encoded, "$argon2id$") {
+		params, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return false, false, err
+		}
+
+		computed := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false, nil
+		}
+
+		rehash = h.cfg.Algorithm != "argon2id" || params.memory != h.cfg.Argon2Memory ||
+			params.iterations != h.cfg.Argon2Iterations || params.parallelism != h.cfg.Argon2Parallelism
+		return true, rehash, nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return false, false, err
+	}
+
+	return true, h.cfg.Algorithm != "bcrypt" || cost != h.cfg.BcryptCost, nil
+}
+
+// Dummy is a hash of a random password, verified against when a login names an unknown user so it takes as long
+func (h *Hasher) Dummy() string {
+	h.dummyOnce.Do(func() {
+		secret := make([]byte, 16)
+		rand.Read(secret)
+		h.dummy, _ = h.Hash(base64.RawStdEncoding.EncodeToString(secret))
+	})
+
+	return h.dummy
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// decodeArgon2 parses $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func decodeArgon2(encoded string) (argon2Params, []byte, []byte, error) {
+	params := argon2Params{}
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2id version")
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %v", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %v", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key: %v", err)
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,105 @@
+package passwords
+
+import (
+	"blogpost/config"
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrWeakPassword is wrapped by every policy violation
+var ErrWeakPassword = errors.New("password rejected")
+
+// bcryptMaxLength is the number of bytes bcrypt looks at, longer passwords would be silently truncated
+const bcryptMaxLength = 72
+
+// Policy decides which new passwords are accepted, it is checked on signup, reset and change
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// breached holds the upper case SHA-1 hex of the known breached passwords
+	breached map[string]struct{}
+}
+
+// LoadPolicy builds the policy from the configuration, reading the breached password list when one is set.
+// The list has one entry per line, either the password itself or its SHA-1 hex as in the
+// Have I Been Pwned downloads ("<sha1>" or "<sha1>:<count>").
+func LoadPolicy(cfg config.PasswordConfig) (*Policy, error) {
+	policy := &Policy{MinLength: cfg.MinLength, MaxLength: cfg.MaxLength, breached: map[string]struct{}{}}
+	if cfg.Algorithm == "bcrypt" && policy.MaxLength > bcryptMaxLength {
+		policy.MaxLength = bcryptMaxLength
+	}
+
+	if cfg.BreachedList == "" {
+		return policy, nil
+	}
+
+	file, err := os.Open(cfg.BreachedList)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the breached password list: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1(hash) {
+			policy.breached[strings.ToUpper(hash)] = struct{}{}
+			continue
+		}
+
+		policy.breached[sha1Hex(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading the breached password list: %v", err)
+	}
+
+	return policy, nil
+}
+
+// Check returns an error wrapping ErrWeakPassword when the password of the user with the mail is not acceptable
+func (p *Policy) Check(password, mail string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("%w: it must be at least %d characters long", ErrWeakPassword, p.MinLength)
+	}
+
+	if length > p.MaxLength || (p.MaxLength == bcryptMaxLength && len(password) > bcryptMaxLength) {
+		return fmt.Errorf("%w: it must be at most %d characters long", ErrWeakPassword, p.MaxLength)
+	}
+
+	mail = strings.ToLower(strings.TrimSpace(mail))
+	local, _, _ := strings.Cut(mail, "@")
+	if lowered := strings.ToLower(password); mail != "" && (lowered == mail || lowered == local) {
+		return fmt.Errorf("%w: it must not be the mail address", ErrWeakPassword)
+	}
+
+	if _, ok := p.breached[sha1Hex(password)]; ok {
+		return fmt.Errorf("%w: it appears in a list of breached passwords", ErrWeakPassword)
+	}
+
+	return nil
+}
+
+func sha1Hex(value string) string {
+	sum := sha1.Sum([]byte(value))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1(value string) bool {
+	if len(value) != 40 {
+		return false
+	}
+
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
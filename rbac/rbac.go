@@ -0,0 +1,34 @@
+package rbac
+
+// Role names
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Permissions checked by the routes and the repository
+const (
+	PostCreate       = "post:create"
+	PostRead         = "post:read"
+	PostEditOwn      = "post:edit:own"
+	PostEditAny      = "post:edit:any"
+	PostDeleteOwn    = "post:delete:own"
+	PostDeleteAny    = "post:delete:any"
+	CommentCreate    = "comment:create"
+	CommentEditOwn   = "comment:edit:own"
+	CommentDeleteOwn = "comment:delete:own"
+	CommentModerate  = "comment:moderate"
+	RoleAssign       = "role:assign"
+	UserManage       = "user:manage"
+)
+
+// Has reports whether permission is in the granted set
+func Has(granted []string, permission string) bool {
+	for _, p := range granted {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"blogpost/models"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// purposes of the tokens mailed to the users
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+)
+
+var (
+	ErrInvalidUserToken = errors.New("invalid or expired token")
+	ErrEmailNotVerified = errors.New("the mail address is not verified yet")
+)
+
+// issueUserToken stores the hash of a new single use token for the user and returns the token itself.
+// Earlier unused tokens of the same purpose are invalidated so only the latest mail works.
+func (db *DbConnection) issueUserToken(tx *gorm.DB, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = tx.Model(&models.UserToken{}).Where("user_id=?", userID).Where("purpose=?", purpose).Where("used_at IS NULL").Update("used_at", now).Error
+	if err != nil {
+		return "", err
+	}
+
+	userToken := models.UserToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	if err := tx.Create(&userToken).Error; err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken marks the token used and returns it, it fails when the token is unknown, expired or already used
+func (db *DbConnection) consumeUserToken(tx *gorm.DB, token, purpose string) (*models.UserToken, error) {
+	userToken := models.UserToken{}
+	err := tx.Where("token_hash=?", hashToken(token)).Where("purpose=?", purpose).First(&userToken).Error
+	if err != nil {
+		return nil, ErrInvalidUserToken
+	}
+
+	if userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		return nil, ErrInvalidUserToken
+	}
+
+	// a concurrent request with the same token must not succeed as well
+	result := tx.Model(&models.UserToken{}).Where("id=?", userToken.ID).Where("used_at IS NULL").Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidUserToken
+	}
+
+	return &userToken, nil
+}
+
+// CreatePasswordReset issues a password reset token for the user with the mail.
+// It returns a nil user without an error when there is no such user, callers must not reveal which mails exist.
+func (db *DbConnection) CreatePasswordReset(mail string) (*models.User, string, error) {
+	user := models.User{}
+	if err := db.DB.Where("mail=?", mail).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			db.Logger.Printf("Password reset requested for unknown mail %v", mail)
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+
+	token, err := db.issueUserToken(db.DB, user.ID, PurposePasswordReset, db.Config.Accounts.ResetTTL)
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when creating the password reset token of the user with ID: %v", err, user.ID)
+		return nil, "", err
+	}
+
+	db.Logger.Printf("Created a password reset token for the user with ID: %v", user.ID)
+	return &user, token, nil
+}
+
+// CreateEmailVerification issues an email verification token for the user with the mail.
+// It returns a nil user without an error when there is no such user or it is already verified.
+func (db *DbConnection) CreateEmailVerification(mail string) (*models.User, string, error) {
+	user := models.User{}
+	if err := db.DB.Where("mail=?", mail).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			db.Logger.Printf("Email verification requested for unknown mail %v", mail)
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		db.Logger.Printf("Email verification requested for the already verified user with ID: %v", user.ID)
+		return nil, "", nil
+	}
+
+	token, err := db.issueUserToken(db.DB, user.ID, PurposeEmailVerification, db.Config.Accounts.VerificationTTL)
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when creating the email verification token of the user with ID: %v", err, user.ID)
+		return nil, "", err
+	}
+
+	db.Logger.Printf("Created an email verification token for the user with ID: %v", user.ID)
+	return &user, token, nil
+}
+
+// VerifyEmail marks the mail of the user the token was issued to as verified
+func (db *DbConnection) VerifyEmail(token string) error {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		userToken, err := db.consumeUserToken(tx, token, PurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.User{}).Where("id=?", userToken.UserID).Where("email_verified_at IS NULL").Update("email_verified_at", time.Now()).Error
+	})
+	if err != nil {
+		db.Logger.Printf("Error verifying the mail: %v", err)
+		return err
+	}
+
+	db.Logger.Printf("Mail verified")
+	return nil
+}
+
+// ResetPassword sets the password of the user the reset token was issued to and revokes all their sessions.
+// A password rejected by the policy leaves the token unused.
+func (db *DbConnection) ResetPassword(token, password string) error {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		userToken, err := db.consumeUserToken(tx, token, PurposePasswordReset)
+		if err != nil {
+			return err
+		}
+
+		user := models.User{}
+		if err := tx.First(&user, "id=?", userToken.UserID).Error; err != nil {
+			return err
+		}
+
+		if err := db.Policy.Check(password, user.Mail); err != nil {
+			return err
+		}
+
+		hash, err := db.Hasher.Hash(password)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.User{}).Where("id=?", user.ID).Update("password", hash).Error; err != nil {
+			return err
+		}
+
+		return revokeSessions(tx, userToken.UserID)
+	})
+	if err != nil {
+		db.Logger.Printf("Error resetting the password: %v", err)
+		return err
+	}
+
+	db.Logger.Printf("Password reset, all the sessions of the user were revoked")
+	return nil
+}
@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"blogpost/rbac"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrSelfSuspension   = errors.New("admins can not suspend themselves")
+	ErrLastAdminRole    = errors.New("the last admin can not be given another role")
+	ErrAlreadySuspended = errors.New("the account is already suspended")
+	ErrNotSuspended     = errors.New("the account is not suspended")
+)
+
+// UserQuery filters the user listing, Search matches part of the mail address
+type UserQuery struct {
+	Search    string
+	Role      string
+	Suspended *bool
+	Page      int
+	PerPage   int
+}
+
+// UserSummary is what admins see of a user in the listing
+type UserSummary struct {
+	ID               uuid.UUID  `json:"id"`
+	Mail             string     `json:"mail"`
+	Role             string     `json:"role"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	SuspendedAt      *time.Time `json:"suspended_at"`
+	SuspendedReason  string     `json:"suspended_reason,omitempty"`
+}
+
+// ListUsers returns a page of users ordered by mail and the number of users matching the query
+func (db *DbConnection) ListUsers(q UserQuery) ([]UserSummary, int64, error) {
+	query := db.DB.Model(&models.User{})
+	if q.Search != "" {
+		query = query.Where("LOWER(mail) LIKE ?", "%"+strings.ToLower(q.Search)+"%")
+	}
+
+	if q.Role != "" {
+		query = query.Where("role=?", q.Role)
+	}
+
+	if q.Suspended != nil {
+		if *q.Suspended {
+			query = query.Where("suspended_at IS NOT NULL")
+		} else {
+			query = query.Where("suspended_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when counting the users", err)
+		return nil, 0, err
+	}
+
+	users := []models.User{}
+	if err := query.Order("mail").Offset((q.Page - 1) * q.PerPage).Limit(q.PerPage).Find(&users).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when listing the users", err)
+		return nil, 0, err
+	}
+
+	summaries := make([]UserSummary, 0, len(users))
+	for _, user := range users {
+		summaries = append(summaries, UserSummary{
+			ID:               user.ID,
+			Mail:             user.Mail,
+			Role:             user.Role,
+			EmailVerifiedAt:  user.EmailVerifiedAt,
+			TwoFactorEnabled: user.TOTPEnabledAt != nil,
+			SuspendedAt:      user.SuspendedAt,
+			SuspendedReason:  user.SuspendedReason,
+		})
+	}
+
+	return summaries, total, nil
+}
+
+// findUser loads the target of an admin action
+func findUser(tx *gorm.DB, userID string) (models.User, error) {
+	user := models.User{}
+	if err := tx.First(&user, "id=?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, ErrUserNotFound
+		}
+		return user, err
+	}
+
+	return user, nil
+}
+
+// ChangeUserRole moves the user to another role. Their sessions are revoked so their tokens carry the new role.
+func (db *DbConnection) ChangeUserRole(principal middleware.Principal, userID, role, ip string) error {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		user, err := findUser(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if !db.roleExists(tx, role) {
+			return ErrUnknownRole
+		}
+
+		if user.Role == role {
+			return nil
+		}
+
+		if user.Role == rbac.RoleAdmin {
+			var admins int64
+			if err := tx.Model(&models.User{}).Where("role=?", rbac.RoleAdmin).Count(&admins).Error; err != nil {
+				return err
+			}
+
+			if admins <= 1 {
+				return ErrLastAdminRole
+			}
+		}
+
+		if err := setRole(tx, user, role); err != nil {
+			return err
+		}
+
+		if err := revokeSessions(tx, user.ID); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, principal, AuditRoleChange, &user.ID, ip, map[string]interface{}{"from": user.Role, "to": role})
+	})
+	if err != nil {
+		db.Logger.Printf("Error changing the role of the user with ID %v: %v", userID, err)
+		return err
+	}
+
+	db.Logger.Printf("User with ID %v gave the user with ID %v the role %v", principal.UserID, userID, role)
+	return nil
+}
+
+// SuspendUser blocks the account from logging in and revokes its sessions. Its API keys stop working until it is unsuspended.
+func (db *DbConnection) SuspendUser(principal middleware.Principal, userID, reason, ip string) error {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		user, err := findUser(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if user.ID == principal.UserID {
+			return ErrSelfSuspension
+		}
+
+		if user.SuspendedAt != nil {
+			return ErrAlreadySuspended
+		}
+
+		err = tx.Model(&models.User{}).Where("id=?", user.ID).Updates(map[string]interface{}{"suspended_at": time.Now(), "suspended_reason": reason}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := revokeSessions(tx, user.ID); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, principal, AuditSuspend, &user.ID, ip, map[string]interface{}{"reason": reason})
+	})
+	if err != nil {
+		db.Logger.Printf("Error suspending the user with ID %v: %v", userID, err)
+		return err
+	}
+
+	db.Logger.Printf("User with ID %v suspended the user with ID %v", principal.UserID, userID)
+	return nil
+}
+
+// UnsuspendUser lets the account log in again
+func (db *DbConnection) UnsuspendUser(principal middleware.Principal, userID, ip string) error {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		user, err := findUser(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if user.SuspendedAt == nil {
+			return ErrNotSuspended
+		}
+
+		err = tx.Model(&models.User{}).Where("id=?", user.ID).Updates(map[string]interface{}{"suspended_at": nil, "suspended_reason": ""}).Error
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(tx, principal, AuditUnsuspend, &user.ID, ip, nil)
+	})
+	if err != nil {
+		db.Logger.Printf("Error unsuspending the user with ID %v: %v", userID, err)
+		return err
+	}
+
+	db.Logger.Printf("User with ID %v unsuspended the user with ID %v", principal.UserID, userID)
+	return nil
+}
+
+// ForceLogout revokes every session of the user
+func (db *DbConnection) ForceLogout(principal middleware.Principal, userID, ip string) error {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		user, err := findUser(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := revokeSessions(tx, user.ID); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, principal, AuditForceLogout, &user.ID, ip, nil)
+	})
+	if err != nil {
+		db.Logger.Printf("Error logging out the user with ID %v: %v", userID, err)
+		return err
+	}
+
+	db.Logger.Printf("User with ID %v logged out the user with ID %v", principal.UserID, userID)
+	return nil
+}
+
+// ResetUserCredentials is for accounts that were taken over or locked out of their second factor: the password,
+// two-factor authentication, sessions and API keys are all removed, and a password reset token is returned for the user
+func (db *DbConnection) ResetUserCredentials(principal middleware.Principal, userID, ip string) (*models.User, string, error) {
+	var user models.User
+	var token string
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = findUser(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.User{}).Where("id=?", user.ID).Updates(map[string]interface{}{
+			"password":        "",
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id=?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.APIKey{}).Where("user_id=?", user.ID).Where("revoked_at IS NULL").Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		if err := revokeSessions(tx, user.ID); err != nil {
+			return err
+		}
+
+		token, err = db.issueUserToken(tx, user.ID, PurposePasswordReset, db.Config.Accounts.ResetTTL)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(tx, principal, AuditResetCredentials, &user.ID, ip, nil)
+	})
+	if err != nil {
+		db.Logger.Printf("Error resetting the credentials of the user with ID %v: %v", userID, err)
+		return nil, "", err
+	}
+
+	db.Logger.Printf("User with ID %v reset the credentials of the user with ID %v", principal.UserID, userID)
+	return &user, token, nil
+}
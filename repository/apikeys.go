@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"blogpost/rbac"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// lastUsedInterval limits how often the last use of a key is written
+const lastUsedInterval = time.Minute
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// CreateAPIKey creates a key acting as the caller with the given scopes, which must be permissions the caller holds.
+// The returned key is shown once, only the hash of its secret is stored.
+func (db *DbConnection) CreateAPIKey(principal middleware.Principal, name string, scopes []string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	granted, err := db.Permissions(principal.UserID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(scopes) == 0 {
+		return "", nil, errors.New("an API key needs at least one scope")
+	}
+
+	for _, scope := range scopes {
+		if !rbac.Has(granted, scope) {
+			return "", nil, fmt.Errorf("can not grant the scope %q, you do not hold that permission", scope)
+		}
+	}
+
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return "", nil, errors.New("the expiry must be in the future")
+	}
+
+	prefixBytes := make([]byte, 6)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", nil, err
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := models.APIKey{
+		ID:         uuid.New(),
+		UserID:     principal.UserID,
+		Name:       name,
+		Prefix:     hex.EncodeToString(prefixBytes),
+		SecretHash: hashToken(secret),
+		Scopes:     strings.Join(scopes, ","),
+		CreatedAt:  time.Now(),
+		ExpiresAt:  expiresAt,
+	}
+
+	if err := db.DB.Create(&key).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when creating an API key for the user with ID: %v", err, principal.UserID)
+		return "", nil, err
+	}
+
+	db.Logger.Printf("User with ID %v created the API key %v (%v) with scopes %v", principal.UserID, key.Prefix, name, key.Scopes)
+	return middleware.APIKeyPrefix + key.Prefix + "_" + secret, &key, nil
+}
+
+// ListAPIKeys returns the keys of the caller, newest first, including the revoked ones
+func (db *DbConnection) ListAPIKeys(principal middleware.Principal, keys *[]models.APIKey) error {
+	if err := db.DB.Where("user_id=?", principal.UserID).Order("created_at desc").Find(keys).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when listing the API keys of the user with ID: %v", err, principal.UserID)
+		return err
+	}
+
+	return nil
+}
+
+// RevokeAPIKey revokes one of the caller's keys, users with user:manage may revoke anybody's
+func (db *DbConnection) RevokeAPIKey(principal middleware.Principal, keyID string) error {
+	query := db.DB.Model(&models.APIKey{}).Where("id=?", keyID).Where("revoked_at IS NULL")
+
+	canManage, err := db.principalCan(principal, rbac.UserManage)
+	if err != nil {
+		return err
+	}
+
+	if !canManage {
+		query = query.Where("user_id=?", principal.UserID)
+	}
+
+	result := query.Update("revoked_at", time.Now())
+	if result.Error != nil {
+		db.Logger.Printf("Error, %v Occured when revoking the API key: %v", result.Error, keyID)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	db.Logger.Printf("User with ID %v revoked the API key %v", principal.UserID, keyID)
+	return nil
+}
+
+// AuthenticateAPIKey checks a bp_<prefix>_<secret> key and returns the principal it acts as
+func (db *DbConnection) AuthenticateAPIKey(rawKey, ip string) (middleware.Principal, error) {
+	parts := strings.SplitN(strings.TrimPrefix(rawKey, middleware.APIKeyPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return middleware.Principal{}, ErrInvalidAPIKey
+	}
+
+	key := models.APIKey{}
+	if err := db.DB.Preload("User").Where("prefix=?", parts[0]).First(&key).Error; err != nil {
+		return middleware.Principal{}, ErrInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(parts[1])), []byte(key.SecretHash)) != 1 {
+		db.Logger.Printf("Wrong secret for the API key %v from %v", key.Prefix, ip)
+		return middleware.Principal{}, ErrInvalidAPIKey
+	}
+
+	if key.User.SuspendedAt != nil {
+		db.Logger.Printf("API key %v of a suspended user used from %v", key.Prefix, ip)
+		return middleware.Principal{}, middleware.ErrAccountSuspended
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		db.Logger.Printf("Revoked or expired API key %v used from %v", key.Prefix, ip)
+		return middleware.Principal{}, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedInterval || key.LastUsedIP != ip {
+		err := db.DB.Model(&models.APIKey{}).Where("id=?", key.ID).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error
+		if err != nil {
+			db.Logger.Printf("Error, %v Occured when recording the use of the API key %v", err, key.Prefix)
+		}
+	}
+
+	return middleware.Principal{
+		UserID:   key.UserID,
+		Email:    key.User.Mail,
+		Role:     key.User.Role,
+		APIKeyID: key.ID,
+		Scopes:   strings.Split(key.Scopes, ","),
+	}, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// actions recorded in the audit log
+const (
+	AuditRoleChange       = "user.role_change"
+	AuditSuspend          = "user.suspend"
+	AuditUnsuspend        = "user.unsuspend"
+	AuditForceLogout      = "user.force_logout"
+	AuditResetCredentials = "user.reset_credentials"
+	AuditUnlock           = "user.unlock"
+	AuditRequire2FA       = "role.require_2fa"
+)
+
+// recordAudit adds an entry to the audit log within the transaction of the action it records
+func recordAudit(tx *gorm.DB, principal middleware.Principal, action string, targetID *uuid.UUID, ip string, details map[string]interface{}) error {
+	encoded := ""
+	if len(details) > 0 {
+		raw, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		encoded = string(raw)
+	}
+
+	entry := models.AuditLog{
+		ID:        uuid.New(),
+		ActorID:   principal.UserID,
+		Action:    action,
+		TargetID:  targetID,
+		Details:   encoded,
+		IP:        ip,
+		CreatedAt: time.Now(),
+	}
+
+	return tx.Create(&entry).Error
+}
+
+// ListAuditLog returns the audit log newest first, optionally only the entries about one user
+func (db *DbConnection) ListAuditLog(targetID string, page, perPage int, entries *[]models.AuditLog) (int64, error) {
+	query := db.DB.Model(&models.AuditLog{})
+	if targetID != "" {
+		query = query.Where("target_id=?", targetID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when counting the audit log", err)
+		return 0, err
+	}
+
+	if err := query.Order("created_at desc").Offset((page - 1) * perPage).Limit(perPage).Find(entries).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when reading the audit log", err)
+		return 0, err
+	}
+
+	return total, nil
+}
@@ -1,50 +1,109 @@
 package repository
 
 import (
+	"blogpost/config"
 	"blogpost/middleware"
 	"blogpost/models"
+	"blogpost/oidc"
+	"blogpost/passwords"
+	"blogpost/rbac"
+	"blogpost/search"
 	"blogpost/utilities"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type DbConnection struct {
 	DB     *gorm.DB
 	Logger *log.Logger
+	Config *config.Config
+	// Keys signs the access tokens
+	Keys *middleware.KeySet
+	// Policy checks the new passwords and Hasher hashes and verifies them
+	Policy *passwords.Policy
+	Hasher *passwords.Hasher
+	// OIDC is the OpenID Connect provider users may log in with, nil when it is not configured
+	OIDC *oidc.Provider
+	// Search finds the posts matching a query, the repository keeps it up to date as posts change
+	Search search.Backend
 }
 
 type Operations interface {
 	AddUser(user *models.User) error
-	Login(email, password string) (string, error)
-	GetRoleID(user *models.User) error
+	Login(email, password string, client Client) (*TokenPair, error)
+	RefreshTokens(refreshToken string, client Client) (*TokenPair, error)
+	Logout(principal middleware.Principal) error
+	RevokeAllSessions(userID uuid.UUID) error
+	ListSessions(principal middleware.Principal) ([]SessionSummary, error)
+	RevokeSession(principal middleware.Principal, sessionID string) error
+	CreatePasswordReset(mail string) (*models.User, string, error)
+	ResetPassword(token, password string) error
+	CreateEmailVerification(mail string) (*models.User, string, error)
+	VerifyEmail(token string) error
+	UnlockAccount(principal middleware.Principal, mail, ip string) error
+	LoginMFA(mfaToken, code string, client Client) (*TokenPair, error)
+	EnrollTOTP(principal middleware.Principal) (string, string, error)
+	ConfirmTOTP(principal middleware.Principal, code string, client Client) ([]string, *TokenPair, error)
+	DisableTOTP(principal middleware.Principal, code string) error
+	SetRoleRequires2FA(principal middleware.Principal, roleName string, required bool, ip string) error
+	CreateAPIKey(principal middleware.Principal, name string, scopes []string, expiresAt *time.Time) (string, *models.APIKey, error)
+	ListAPIKeys(principal middleware.Principal, keys *[]models.APIKey) error
+	RevokeAPIKey(principal middleware.Principal, keyID string) error
+	StartOIDCLogin(ctx context.Context) (string, string, error)
+	FinishOIDCLogin(ctx context.Context, state, code string, client Client) (*TokenPair, error)
+	Profile(principal middleware.Principal) (*Profile, error)
+	ChangePassword(principal middleware.Principal, current, password string, client Client) (*TokenPair, error)
+	RequestEmailChange(principal middleware.Principal, mail, current, ip string) (*models.User, string, error)
+	ConfirmEmailChange(token string) error
+	DeleteAccount(principal middleware.Principal, current, ip string) error
+	ListUsers(query UserQuery) ([]UserSummary, int64, error)
+	ChangeUserRole(principal middleware.Principal, userID, role, ip string) error
+	SuspendUser(principal middleware.Principal, userID, reason, ip string) error
+	UnsuspendUser(principal middleware.Principal, userID, ip string) error
+	ForceLogout(principal middleware.Principal, userID, ip string) error
+	ResetUserCredentials(principal middleware.Principal, userID, ip string) (*models.User, string, error)
+	ListAuditLog(targetID string, page, perPage int, entries *[]models.AuditLog) (int64, error)
 	AddPost(*models.Post) error
+	ChangePostStatus(principal middleware.Principal, postID, status string, publishAt *time.Time) (*models.Post, error)
+	ListRevisions(principal middleware.Principal, postID string, page PageRequest, revisions *[]models.PostRevision) (PageInfo, error)
+	DiffRevisions(principal middleware.Principal, postID string, from, to int) (*RevisionDiff, error)
+	RestoreRevision(principal middleware.Principal, postID string, number int) (*models.Post, *models.PostRevision, error)
 	GetPostID(post *models.Post) error
-	SearchAllPost(post *[]models.Post) error
-	UpdatePostByID(ID string, mail string, data map[string]interface{}) (*models.Post, error)
-	DeletePostByID(mail string, PostID string, post *models.Post) error
-	GetPostBasedOnRoleID(mail string, post *[]models.Post) error
-	GetPostBasedOnCategory(category string, post *[]models.Post) error
-	GetPostbasedOnPostID(mail string, postID string, post *models.Post) error
+	ListPosts(q PostQuery, page PageRequest) ([]SearchResult, PageInfo, *Facets, error)
+	UpdatePostByID(principal middleware.Principal, ID string, data map[string]interface{}) (*models.Post, error)
+	DeletePostByID(principal middleware.Principal, PostID string, post *models.Post) error
+	GetPostBasedOnRoleID(principal middleware.Principal, page PageRequest, post *[]models.Post) (PageInfo, error)
+	GetPostBasedOnCategory(category string, page PageRequest, post *[]models.Post) (PageInfo, error)
+	GetPostbasedOnPostID(principal middleware.Principal, postID string, post *models.Post) error
 	GetAllCategory(Post *[]models.Post) error
 	GetPostStatistics(post *models.Post, postCount, commentCount *int64) error
-	AddComments(mail string, comment *models.Comments) error
-	UpdateCommentByID(mail string, commentID string, data map[string]interface{}) error
-	DeleteCommentByID(mail string, commentID string, comment *models.Comments) error
-	GetCommentsBasedOnUser(mail string, comment *[]models.Comments) error
-	GetCommentsBasedOnPostID(postID string, comment *[]models.Comments) error
+	AddComments(principal middleware.Principal, comment *models.Comments) error
+	UpdateCommentByID(principal middleware.Principal, commentID string, data map[string]interface{}) error
+	DeleteCommentByID(principal middleware.Principal, commentID string, comment *models.Comments) error
+	GetCommentsBasedOnUser(principal middleware.Principal, page PageRequest, comment *[]models.Comments) (PageInfo, error)
+	GetCommentsBasedOnPostID(postID string, page PageRequest, comment *[]models.Comments) (PageInfo, error)
 }
 
-func NewDbConnection(db *gorm.DB, logger *log.Logger) *DbConnection {
-	return &DbConnection{DB: db, Logger: logger}
+func NewDbConnection(db *gorm.DB, logger *log.Logger, cfg *config.Config, keys *middleware.KeySet, policy *passwords.Policy, searchBackend search.Backend) *DbConnection {
+	connection := &DbConnection{DB: db, Logger: logger, Config: cfg, Keys: keys, Policy: policy, Hasher: passwords.NewHasher(cfg.Password), Search: searchBackend}
+	if cfg.OIDC.Issuer != "" {
+		connection.OIDC = oidc.NewProvider(cfg.OIDC.Issuer, cfg.OIDC.ClientID, cfg.OIDC.ClientSecret, cfg.OIDC.RedirectURL, cfg.OIDC.Scopes)
+	}
+
+	return connection
 }
 
+// AddUser creates the user with the plain text password in user.Password, which is replaced by its hash
 func (db *DbConnection) AddUser(user *models.User) error {
 	user.ID = uuid.New()
+	// new users start unverified whatever the request says
+	user.EmailVerifiedAt = nil
 
 	err := utilities.ValidateStruct(user)
 	if err != nil {
@@ -52,7 +111,24 @@ func (db *DbConnection) AddUser(user *models.User) error {
 		return err
 	}
 
-	if err := db.DB.Create(&user).Error; err != nil {
+	if err := db.Policy.Check(user.Password, user.Mail); err != nil {
+		db.Logger.Printf("Password of the new user rejected: %v", err)
+		return err
+	}
+
+	if user.Password, err = db.Hasher.Hash(user.Password); err != nil {
+		db.Logger.Printf("Error hashing the password: %v", err)
+		return err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		return assignRole(tx, user.ID, user.Role)
+	})
+	if err != nil {
 		db.Logger.Printf("Error creating user: %v", err)
 		return err
 	}
@@ -61,80 +137,134 @@ func (db *DbConnection) AddUser(user *models.User) error {
 	return nil
 }
 
-// Login
-func (db *DbConnection) Login(mail, password string) (string, error) {
+// Login checks the credentials and starts a new session with a fresh access and refresh token
+// Failed attempts are counted per account and per client address, see throttle.go.
+func (db *DbConnection) Login(mail, password string, client Client) (*TokenPair, error) {
+	ip := client.IP
+
 	if mail == "" || password == "" {
 		db.Logger.Printf("mail or password can't be empty")
-		return "", fmt.Errorf("mail or password can't be empty")
+		return nil, fmt.Errorf("mail or password can't be empty")
+	}
+
+	keys := []string{mailThrottleKey(mail)}
+	if ip != "" {
+		keys = append(keys, ipThrottleKey(ip))
+	}
+
+	if err := db.checkThrottle(keys...); err != nil {
+		db.Logger.Printf("Login for mailID %v from %v refused: %v", mail, ip, err)
+		return nil, err
 	}
 
 	var checkingUser models.User
 
-	if err := db.DB.Debug().First(&checkingUser, "mail=?", mail).Error; err != nil {
+	err := db.DB.Debug().First(&checkingUser, "mail=?", mail).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		db.Logger.Printf("%v", err)
-		return "", err
+		return nil, err
 	}
 
-	err := bcrypt.CompareHashAndPassword([]byte(checkingUser.Password), []byte(password))
+	// an unknown mail takes as long to reject as a wrong password
+	hash := checkingUser.Password
 	if err != nil {
-		db.Logger.Printf("Error in validating the user: %v", err)
-		return "", err
+		hash = db.Hasher.Dummy()
 	}
 
-	var token string
+	valid, rehash, verifyErr := db.Hasher.Verify(password, hash)
+	if verifyErr != nil {
+		db.Logger.Printf("Error verifying the password of mailID %v: %v", mail, verifyErr)
+	}
 
-	if checkingUser.Role == "admin" {
-		token, err = middleware.AdminToken(mail, checkingUser.ID)
-		if err != nil {
-			db.Logger.Printf("Error creating the token: %v", err)
-			return "", nil
-		}
-	} else if checkingUser.Role == "user" {
-		token, err = middleware.MemberToken(mail, checkingUser.ID)
-		if err != nil {
-			db.Logger.Printf("Error creating the token: %v", err)
-			return "", nil
-		}
-	} else {
+	if !valid || err != nil {
+		db.Logger.Printf("Invalid credentials for mailID %v from %v", mail, ip)
+		db.recordLoginFailure(mail, ip)
+		return nil, ErrInvalidCredentials
+	}
+
+	// hashes made with another algorithm or older parameters are upgraded while the password is at hand
+	if rehash {
+		db.upgradeHash(checkingUser, password)
+	}
+
+	// the account is no longer under attack, the client address keeps its count
+	if err := db.DB.Where("throttle_key=?", mailThrottleKey(mail)).Delete(&models.LoginThrottle{}).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when clearing the failed logins of %v", err, mail)
+	}
+
+	if checkingUser.EmailVerifiedAt == nil {
+		db.Logger.Printf("User with mailID %v has not verified the mail yet", mail)
+		return nil, ErrEmailNotVerified
+	}
+
+	if checkingUser.Role != rbac.RoleAdmin && checkingUser.Role != rbac.RoleMember {
 		db.Logger.Printf("invalid user role")
-		return "", fmt.Errorf("invalid user role")
+		return nil, fmt.Errorf("invalid user role")
+	}
+
+	// the password is not enough for accounts with two-factor authentication
+	if checkingUser.TOTPEnabledAt != nil {
+		return nil, db.mfaChallenge(checkingUser)
+	}
+
+	// every login starts a new refresh token family
+	tokens, err := db.startSession(checkingUser, false, client)
+	if err != nil {
+		return nil, err
 	}
 
 	db.Logger.Printf("User with mailID %v logged in successfully", mail)
-	return token, nil
+	return tokens, nil
 }
 
-// GetRoleID
-func (db *DbConnection) GetRoleID(user *models.User) error {
-	if err := db.DB.Debug().First(&user, "mail=?", user.Mail).Error; err != nil {
-		db.Logger.Printf("invalid MailID!!! kindly Check it")
-		return fmt.Errorf("invalid MailID!!! kindly Check it")
+// upgradeHash replaces the stored hash of the user with one made with the configured algorithm and parameters
+func (db *DbConnection) upgradeHash(user models.User, password string) {
+	hash, err := db.Hasher.Hash(password)
+	if err == nil {
+		// only replaced when unchanged, a concurrent password change wins
+		err = db.DB.Model(&models.User{}).Where("id=?", user.ID).Where("password=?", user.Password).Update("password", hash).Error
 	}
 
-	db.Logger.Println("user role id retrived")
-	return nil
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when upgrading the password hash of the user with ID: %v", err, user.ID)
+		return
+	}
+
+	db.Logger.Printf("Upgraded the password hash of the user with ID: %v", user.ID)
 }
 
 // ----------------------------------------------POST---------------------------------------------------------------------------
 func (db *DbConnection) AddPost(post *models.Post) error {
-	var checkingUser models.User
+	canCreate, err := db.HasPermission(post.RoleID, rbac.PostCreate)
+	if err != nil {
+		return err
+	}
 
-	if err := db.DB.Debug().Where("role=?").First(&checkingUser, "id=?", post.RoleID).Error; err != nil {
+	if !canCreate {
 		db.Logger.Println("invalid RoleID! Kindly Check it")
 		return fmt.Errorf("invalid RoleID! Kindly Check it")
 	}
 
 	post.ID = uuid.New()
-	post.PostDate = time.Now()
+	if err := preparePostStatus(post, time.Now()); err != nil {
+		return err
+	}
 
-	fmt.Println("post---------> before:", post)
+	// the content the post starts with is its first revision
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&post).Error; err != nil {
+			return err
+		}
 
-	if err := db.DB.Create(&post).Error; err != nil {
+		_, err := addRevision(tx, *post, post.RoleID, nil)
+		return err
+	})
+	if err != nil {
 		db.Logger.Printf("Error creating the post: %v", err)
 		return err
 	}
 
-	fmt.Println("post---------> after:", post)
+	db.indexPost(*post)
 
 	db.Logger.Printf("Added post with ID: %v", post.ID)
 	return nil
@@ -151,32 +281,19 @@ func (db *DbConnection) GetPostID(post *models.Post) error {
 	return nil
 }
 
-// Search all the posts
-func (db *DbConnection) SearchAllPost(post *[]models.Post) error {
-	if err := db.DB.Debug().Find(&post).Error; err != nil {
-		db.Logger.Printf("%v", err)
-		return err
-	}
-
-	if len(*post) == 0 {
-		return fmt.Errorf("empty Post")
-	}
-
-	db.Logger.Println("Retrived all the post")
-	return nil
+// posts are listed newest first
+var postOrder = keyset[models.Post]{
+	Column: "post_date",
+	Desc:   true,
+	Key:    func(post models.Post) cursor { return cursor{Time: post.PostDate, ID: post.ID} },
 }
 
 // GetPostbasedOnPostID
-func (db *DbConnection) GetPostbasedOnPostID(mail string, postID string, post *models.Post) error {
+func (db *DbConnection) GetPostbasedOnPostID(principal middleware.Principal, postID string, post *models.Post) error {
 	user := models.User{}
 	view := models.Views{}
 
-	if mail == "" {
-		db.Logger.Printf("mailID can not be empty")
-		return fmt.Errorf("mailID can not be empty")
-	}
-
-	if err := db.DB.Debug().Where("mail=?", mail).Where("role=?", "user").First(&user).Error; err != nil {
+	if err := db.DB.First(&user, "id=?", principal.UserID).Error; err != nil {
 		db.Logger.Printf("Error finding the user: %v", err)
 		return fmt.Errorf("unauthorized")
 	}
@@ -186,13 +303,29 @@ func (db *DbConnection) GetPostbasedOnPostID(mail string, postID string, post *m
 		return fmt.Errorf("PostID can not be empty")
 	}
 
-	if err := db.DB.Debug().First(&post, "id=?", postID).Error; err != nil {
+	if err := db.DB.First(&post, "id=?", postID).Error; err != nil {
 		db.Logger.Printf("Error, %v Occured when searching the post with ID: %v", err, postID)
 		return err
 	}
 
+	// unpublished posts are only shown to their author and the editors, without counting the view
+	if post.Status != models.PostPublished {
+		canEditAny, err := db.principalCan(principal, rbac.PostEditAny)
+		if err != nil {
+			return err
+		}
+
+		if post.RoleID != user.ID && !canEditAny {
+			db.Logger.Printf("The post with ID %v is not published", postID)
+			return gorm.ErrRecordNotFound
+		}
+
+		db.Logger.Println("Retrived the unpublished post with ID:", postID)
+		return nil
+	}
+
 	viewscount := post.ViewsCount
-	if err := db.DB.Debug().Model(&post).Where("id=?", postID).Update("views_count", viewscount+1).Error; err != nil {
+	if err := db.DB.Model(&post).Where("id=?", postID).Update("views_count", viewscount+1).Error; err != nil {
 		db.Logger.Printf("Error, %v Occured when searching the post with ID: %v", err, postID)
 		return err
 	}
@@ -203,19 +336,18 @@ func (db *DbConnection) GetPostbasedOnPostID(mail string, postID string, post *m
 	existingViewLog.PostID = post.ID
 	existingViewLog.IsViewed = false
 	existingViewLog.Views = post.ViewsCount
-	if err := db.DB.Debug().Create(&existingViewLog).Error; err != nil {
+	if err := db.DB.Create(&existingViewLog).Error; err != nil {
 		existingViewLog.IsViewed = true
 		db.Logger.Printf("Error, %v Occured when creating the view table: %v", err, postID)
 		return err
 	}
 
-	if err := db.DB.Debug().First(&view, "post_id=?", postID).Error; err != nil {
+	if err := db.DB.First(&view, "post_id=?", postID).Error; err != nil {
 		db.Logger.Printf("Error, %v Occured when searching the post with ID: %v", err, postID)
 		return err
 	}
 
 	if !view.IsViewed {
-		fmt.Println("Hoi")
 		post.UserCount++
 		view.IsViewed = true
 	}
@@ -225,16 +357,11 @@ func (db *DbConnection) GetPostbasedOnPostID(mail string, postID string, post *m
 }
 
 // Update the post content
-func (db *DbConnection) UpdatePostByID(PostID string, mail string, data map[string]interface{}) (*models.Post, error) {
+func (db *DbConnection) UpdatePostByID(principal middleware.Principal, PostID string, data map[string]interface{}) (*models.Post, error) {
 	post := models.Post{}
 	user := models.User{}
 
-	if mail == "" {
-		db.Logger.Printf("mailID can not be empty")
-		return nil, fmt.Errorf("mailID can not be empty")
-	}
-
-	if err := db.DB.Debug().Where("mail=?", mail).Where("role=?", "admin").First(&user).Error; err != nil {
+	if err := db.DB.Debug().First(&user, "id=?", principal.UserID).Error; err != nil {
 		db.Logger.Printf("Error finding the user: %v", err)
 		return nil, fmt.Errorf("unauthorized")
 	}
@@ -244,32 +371,56 @@ func (db *DbConnection) UpdatePostByID(PostID string, mail string, data map[stri
 		return nil, fmt.Errorf("PostID can not be empty")
 	}
 
-	if err := db.DB.Debug().Where("role_id=?", user.ID).First(&post, "id=?", PostID).Error; err != nil {
+	content, err := postContent(data)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err = db.editablePost(principal, PostID)
+	if err != nil {
 		db.Logger.Printf("Error: %v", err)
 		return nil, err
 	}
 
-	for d, value := range data {
-		if err := db.DB.Debug().Model(&post).Where("id=?", PostID).Update(d, value).Error; err != nil {
-			db.Logger.Printf("Error: %v", err)
-			return nil, err
+	// the edit and the revision recording its result are written together
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if len(content) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&models.Post{}).Where("id=?", post.ID).Updates(content).Error; err != nil {
+			return err
+		}
+
+		if err := tx.First(&post, "id=?", post.ID).Error; err != nil {
+			return err
+		}
+
+		// an edit that leaves the content as it was adds no revision
+		changed, err := contentChanged(tx, post)
+		if err != nil || !changed {
+			return err
 		}
+
+		_, err = addRevision(tx, post, user.ID, nil)
+		return err
+	})
+	if err != nil {
+		db.Logger.Printf("Error: %v", err)
+		return nil, err
 	}
 
+	db.indexPost(post)
+
 	db.Logger.Printf("Updated the post content with ID: %v", post.ID)
 	return &post, nil
 }
 
 // Delete the post by Id dbOperation
-func (db *DbConnection) DeletePostByID(mail string, postID string, post *models.Post) error {
+func (db *DbConnection) DeletePostByID(principal middleware.Principal, postID string, post *models.Post) error {
 	user := models.User{}
 
-	if mail == "" {
-		db.Logger.Printf("mailID can not be empty")
-		return fmt.Errorf("mailID can not be empty")
-	}
-
-	if err := db.DB.Debug().Where("mail=?", mail).Where("role=?", "admin").First(&user).Error; err != nil {
+	if err := db.DB.Debug().First(&user, "id=?", principal.UserID).Error; err != nil {
 		db.Logger.Printf("unauthorized")
 		return fmt.Errorf("unauthorized")
 	}
@@ -279,65 +430,75 @@ func (db *DbConnection) DeletePostByID(mail string, postID string, post *models.
 		return fmt.Errorf("PostID can not be empty")
 	}
 
-	if err := db.DB.Debug().Where("role_id=?", user.ID).First(&post, "id=?", postID).Error; err != nil {
+	// authors delete their own posts, deleting someone else's needs post:delete:any
+	query := db.DB.Debug().Where("id=?", postID)
+	canDeleteAny, err := db.principalCan(principal, rbac.PostDeleteAny)
+	if err != nil {
+		return err
+	}
+
+	if !canDeleteAny {
+		query = query.Where("role_id=?", user.ID)
+	}
+
+	if err := query.First(&post).Error; err != nil {
 		db.Logger.Printf("Error, %v Occured when searching the post with ID: %v", err, postID)
 		return err
 	}
 
-	if err := db.DB.Debug().Delete(&post, "ID=?", postID).Error; err != nil {
+	// the foreign keys cascade on most databases, sqlite only enforces them when asked to
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("post_id=?", post.ID).Delete(&models.PostRevision{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&post, "ID=?", postID).Error
+	})
+	if err != nil {
 		db.Logger.Printf("Error %v Occured when deleting the post with ID: %v", err, postID)
 		return err
 	}
 
+	db.unindexPost(post.ID)
+
 	db.Logger.Printf("Deleted the post with ID: %v", post.ID)
 	return nil
 }
 
 // to get the post based on role id db operation
-func (db *DbConnection) GetPostBasedOnRoleID(mail string, post *[]models.Post) error {
+func (db *DbConnection) GetPostBasedOnRoleID(principal middleware.Principal, page PageRequest, post *[]models.Post) (PageInfo, error) {
 	user := models.User{}
 
-	if mail == "" {
-		db.Logger.Printf("mailID can not be empty")
-		return fmt.Errorf("mailID can not be empty")
-	}
-
-	if err := db.DB.Debug().Where("mail=?", mail).Where("role=?", "admin").First(&user).Error; err != nil {
-		db.Logger.Printf("Error %v Occured when searching the user with mailID: %v", err, mail)
-		return fmt.Errorf("unauthorized")
+	if err := db.DB.Debug().First(&user, "id=?", principal.UserID).Error; err != nil {
+		db.Logger.Printf("Error %v Occured when searching the user with ID: %v", err, principal.UserID)
+		return PageInfo{}, fmt.Errorf("unauthorized")
 	}
 
-	if err := db.DB.Debug().Where("role_id=?", user.ID).Find(&post).Error; err != nil {
+	info, err := paginate(db.DB.Debug().Model(&models.Post{}).Where("role_id=?", user.ID), page, postOrder, post)
+	if err != nil {
 		db.Logger.Printf("Error %v Occured when searching the post posted by the user with roleID: %v", err, user.ID)
-		return err
-	}
-
-	if len(*post) == 0 {
-		return fmt.Errorf("empty Post")
+		return info, err
 	}
 
 	db.Logger.Printf("Retrived the posts posted the user with ID:%v", user.ID)
-	return nil
+	return info, nil
 }
 
 // to get the post based on category db operation
-func (db *DbConnection) GetPostBasedOnCategory(category string, post *[]models.Post) error {
-	if err := db.DB.Debug().Where("category=?", category).Find(&post).Error; err != nil {
+func (db *DbConnection) GetPostBasedOnCategory(category string, page PageRequest, post *[]models.Post) (PageInfo, error) {
+	info, err := paginate(db.publishedPosts().Where("category=?", category), page, postOrder, post)
+	if err != nil {
 		db.Logger.Printf("Error %v Occured when searching the post based on the category: %v", err, category)
-		return err
-	}
-
-	if len(*post) == 0 {
-		return fmt.Errorf("empty Post")
+		return info, err
 	}
 
 	db.Logger.Printf("Retrived the posts based ont the category:%v", category)
-	return nil
+	return info, nil
 }
 
 // to get the post based on category db operation
 func (db *DbConnection) GetAllCategory(post *[]models.Post) error {
-	if err := db.DB.Debug().Select("category").Find(&post).Error; err != nil {
+	if err := db.publishedPosts().Select("category").Find(&post).Error; err != nil {
 		db.Logger.Printf("Error, %v Occured when retriving all the categories", err)
 		return err
 	}
@@ -348,12 +509,13 @@ func (db *DbConnection) GetAllCategory(post *[]models.Post) error {
 
 // to get the post statistics db operation
 func (db *DbConnection) GetPostStatistics(post *models.Post, postCount, commentCount *int64) error {
-	if err := db.DB.Debug().Model(&post).Count(postCount).Error; err != nil {
+	if err := db.publishedPosts().Count(postCount).Error; err != nil {
 		db.Logger.Printf("Error, %v Occured when counting the post", err)
 		return err
 	}
 
-	if err := db.DB.Debug().Model(&models.Comments{}).Count(commentCount).Error; err != nil {
+	err := db.DB.Model(&models.Comments{}).Where("post_id IN (?)", db.publishedPosts().Select("id")).Count(commentCount).Error
+	if err != nil {
 		db.Logger.Printf("Error %v Occured when counting the comment", err)
 		return err
 	}
@@ -364,18 +526,19 @@ func (db *DbConnection) GetPostStatistics(post *models.Post, postCount, commentC
 
 // ---------------------------------Comments---------------------------------------------------------------------------
 // to add comments db operation
-func (db *DbConnection) AddComments(mail string, comment *models.Comments) error {
+func (db *DbConnection) AddComments(principal middleware.Principal, comment *models.Comments) error {
 	comment.ID = uuid.New()
+	comment.CreatedAt = time.Now()
 	post := models.Post{}
 
-	if mail == "" {
-		db.Logger.Printf("mailID can not be empty")
-		return fmt.Errorf("mailID can not be empty")
+	if err := db.DB.Debug().First(&models.User{}, "id=?", principal.UserID).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when searching the user with ID: %v", err, principal.UserID)
+		return fmt.Errorf("unauthorized")
 	}
 
-	if err := db.DB.Debug().Where("mail=?", mail).Where("role=?", "user").First(&models.User{}).Error; err != nil {
-		db.Logger.Printf("Error, %v Occured when searching the user based on the mail: %v", err, mail)
-		return fmt.Errorf("unauthorized")
+	if err := db.publishedPosts().First(&post, "id=?", comment.PostID).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when searching the published post with ID: %v", err, comment.PostID)
+		return ErrPostNotPublished
 	}
 
 	if err := db.DB.Create(&comment).Error; err != nil {
@@ -404,18 +567,29 @@ func (db *DbConnection) AddComments(mail string, comment *models.Comments) error
 	return nil
 }
 
+// commentContent checks the fields of an edit of a comment, only its feedback can change
+func commentContent(data map[string]interface{}) (map[string]interface{}, error) {
+	for field := range data {
+		if field != "feedback" {
+			return nil, fmt.Errorf("%w, not %v", ErrCommentNotEditable, field)
+		}
+	}
+
+	feedback, ok := data["feedback"].(string)
+	if !ok || feedback == "" {
+		return nil, errors.New("feedback must be a non-empty string")
+	}
+
+	return map[string]interface{}{"feedback": feedback}, nil
+}
+
 // Update the comment added by the user
-func (db *DbConnection) UpdateCommentByID(mail string, commentID string, data map[string]interface{}) error {
+func (db *DbConnection) UpdateCommentByID(principal middleware.Principal, commentID string, data map[string]interface{}) error {
 	var comment models.Comments
 	user := models.User{}
 
-	if mail == "" {
-		db.Logger.Printf("mailID can not be empty")
-		return fmt.Errorf("mailID can not be empty")
-	}
-
-	if err := db.DB.Debug().Where("mail=?", mail).Where("role=?", "user").First(&user).Error; err != nil {
-		db.Logger.Printf("Error %v Occured when searching the user with mail: %v", err, mail)
+	if err := db.DB.Debug().First(&user, "id=?", principal.UserID).Error; err != nil {
+		db.Logger.Printf("Error %v Occured when searching the user with ID: %v", err, principal.UserID)
 		return fmt.Errorf("unauthorized")
 	}
 
@@ -424,7 +598,18 @@ func (db *DbConnection) UpdateCommentByID(mail string, commentID string, data ma
 		return fmt.Errorf("commentID can not be empty")
 	}
 
-	if err := db.DB.Debug().Where("id=?", commentID).Where("role_id=?", user.ID).First(&comment).Error; err != nil {
+	// members edit their own comments, moderators edit any
+	query := db.DB.Debug().Where("id=?", commentID)
+	canModerate, err := db.principalCan(principal, rbac.CommentModerate)
+	if err != nil {
+		return err
+	}
+
+	if !canModerate {
+		query = query.Where("role_id=?", user.ID)
+	}
+
+	if err := query.First(&comment).Error; err != nil {
 		db.Logger.Printf("Error %v Occured when searching the comment posted by the user with ID: %v", err, user.ID)
 		return fmt.Errorf("unauthorized")
 	}
@@ -434,13 +619,14 @@ func (db *DbConnection) UpdateCommentByID(mail string, commentID string, data ma
 		return err
 	}
 
-	fmt.Println("Data in update comment in handler", data)
+	content, err := commentContent(data)
+	if err != nil {
+		return err
+	}
 
-	for d, value := range data {
-		if err := db.DB.Model(&models.Comments{}).Where("id=?", commentID).Update(d, value).Error; err != nil {
-			db.Logger.Printf("Error %v Occured when updating the comment with ID: %v", err, commentID)
-			return err
-		}
+	if err := db.DB.Model(&models.Comments{}).Where("id=?", commentID).Updates(content).Error; err != nil {
+		db.Logger.Printf("Error %v Occured when updating the comment with ID: %v", err, commentID)
+		return err
 	}
 
 	db.Logger.Printf("Updated the comment with ID:%v", commentID)
@@ -448,15 +634,10 @@ func (db *DbConnection) UpdateCommentByID(mail string, commentID string, data ma
 }
 
 // Delete comment by ID  DBOperation
-func (db *DbConnection) DeleteCommentByID(mail string, commentID string, comment *models.Comments) error {
+func (db *DbConnection) DeleteCommentByID(principal middleware.Principal, commentID string, comment *models.Comments) error {
 	user := &models.User{}
-	if mail == "" {
-		db.Logger.Printf("mailID can not be empty")
-		return fmt.Errorf("mailID can not be empty")
-	}
-
-	if err := db.DB.Debug().Where("mail=?", mail).Where("role=?", "user").First(&user).Error; err != nil {
-		db.Logger.Printf("Error, %v Occured when searching the user with mail: %v", err, mail)
+	if err := db.DB.Debug().First(&user, "id=?", principal.UserID).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when searching the user with ID: %v", err, principal.UserID)
 		return fmt.Errorf("unauthorized")
 	}
 
@@ -465,7 +646,18 @@ func (db *DbConnection) DeleteCommentByID(mail string, commentID string, comment
 		return fmt.Errorf("commentID can not be empty")
 	}
 
-	if err := db.DB.Debug().Where("id=?", commentID).Where("role_id=?", user.ID).First(&comment).Error; err != nil {
+	// members delete their own comments, moderators delete any
+	query := db.DB.Debug().Where("id=?", commentID)
+	canModerate, err := db.principalCan(principal, rbac.CommentModerate)
+	if err != nil {
+		return err
+	}
+
+	if !canModerate {
+		query = query.Where("role_id=?", user.ID)
+	}
+
+	if err := query.First(&comment).Error; err != nil {
 		db.Logger.Printf("Error, %v Occured when searching the comments with ID: %v", err, commentID)
 		return fmt.Errorf("unauthorized")
 	}
@@ -501,30 +693,39 @@ func (db *DbConnection) GetAllComments(comment *[]models.Comments) error {
 	return nil
 }
 
-// Get all the comments added by the user
-func (db *DbConnection) GetCommentsBasedOnUser(mail string, comment *[]models.Comments) error {
-	user := models.User{}
-	if mail == "" {
-		db.Logger.Printf("mailID can not be empty")
-		return fmt.Errorf("mailID can not be empty")
-	}
+// the comments of a post read oldest first, like a conversation
+var commentOrder = keyset[models.Comments]{
+	Column: "created_at",
+	Key:    func(comment models.Comments) cursor { return cursor{Time: comment.CreatedAt, ID: comment.ID} },
+}
 
-	if err := db.DB.Debug().Where("mail=?", mail).Where("role=?", "user").First(&user).Error; err != nil {
-		db.Logger.Printf("Error, %v Occured when searching the user with mail: %v", err, mail)
-		return fmt.Errorf("unauthorized")
+// the comments of a user are listed newest first
+var userCommentOrder = keyset[models.Comments]{
+	Column: "created_at",
+	Desc:   true,
+	Key:    commentOrder.Key,
+}
+
+// Get the comments added by the user, a page at a time
+func (db *DbConnection) GetCommentsBasedOnUser(principal middleware.Principal, page PageRequest, comment *[]models.Comments) (PageInfo, error) {
+	user := models.User{}
+	if err := db.DB.Debug().First(&user, "id=?", principal.UserID).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when searching the user with ID: %v", err, principal.UserID)
+		return PageInfo{}, fmt.Errorf("unauthorized")
 	}
 
-	if err := db.DB.Debug().Where("role_id=?", user.ID).Find(&comment).Error; err != nil {
+	info, err := paginate(db.DB.Debug().Model(&models.Comments{}).Where("role_id=?", user.ID), page, userCommentOrder, comment)
+	if err != nil {
 		db.Logger.Printf("Error %v Occured when searching the comment posted by the user with ID: %v", err, user.ID)
-		return err
+		return info, err
 	}
 
-	db.Logger.Printf("Retrived all the comments posted by the user with ID:%v", user.ID)
-	return nil
+	db.Logger.Printf("Retrived the comments posted by the user with ID:%v", user.ID)
+	return info, nil
 }
 
 // Get all the comments based ont the postID
-func (db *DbConnection) GetCommentsBasedOnPostID(postID string, comment *[]models.Comments) error {
+func (db *DbConnection) GetCommentsBasedOnPostID(postID string, page PageRequest, comment *[]models.Comments) (PageInfo, error) {
 	// if mail == "" {
 	// 	return fmt.Errorf("mailID can not be empty")
 	// }
@@ -534,14 +735,15 @@ func (db *DbConnection) GetCommentsBasedOnPostID(postID string, comment *[]model
 	// }
 	if postID == "" {
 		db.Logger.Printf("postID can not be empty")
-		return fmt.Errorf("postID can not be empty")
+		return PageInfo{}, fmt.Errorf("postID can not be empty")
 	}
 
-	if err := db.DB.Debug().Where("post_id=?", postID).Find(&comment).Error; err != nil {
+	info, err := paginate(db.DB.Debug().Model(&models.Comments{}).Where("post_id=? AND post_id IN (?)", postID, db.publishedPosts().Select("id")), page, commentOrder, comment)
+	if err != nil {
 		db.Logger.Printf("Error %v Occured when searching the comment for the post with ID: %v", err, postID)
-		return err
+		return info, err
 	}
 
-	db.Logger.Printf("Retrived all the comments for the post with ID:%v", postID)
-	return nil
+	db.Logger.Printf("Retrived the comments for the post with ID:%v", postID)
+	return info, nil
 }
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPostNotFound       = errors.New("post not found")
+	ErrInvalidStatus      = errors.New("status must be draft, scheduled, published or archived")
+	ErrInvalidTransition  = errors.New("invalid status change")
+	ErrPublishAtRequired  = errors.New("scheduling a post needs a publish_at in the future")
+	ErrStatusNotEditable  = errors.New("status and publish_at are changed through the status endpoint of the post")
+	ErrFieldNotEditable   = errors.New("only the title, description and category of a post can be edited")
+	ErrPostNotPublished   = errors.New("comments can only be added to published posts")
+	ErrCommentNotEditable = errors.New("only the feedback of a comment can be edited")
+)
+
+// postContentFields are the columns an edit of a post may change, everything else has its own endpoint or is
+// kept by the service
+var postContentFields = []string{"title", "description", "category"}
+
+// postContent checks the fields of an edit, a field name in any other spelling is refused as well
+func postContent(data map[string]interface{}) (map[string]interface{}, error) {
+	content := make(map[string]interface{}, len(data))
+	for field, value := range data {
+		if !slices.Contains(postContentFields, field) {
+			switch strings.ToLower(field) {
+			case "status", "publish_at", "publishat":
+				return nil, ErrStatusNotEditable
+			}
+			return nil, fmt.Errorf("%w, not %v", ErrFieldNotEditable, field)
+		}
+
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v must be a string", field)
+		}
+		content[field] = text
+	}
+
+	return content, nil
+}
+
+// postTransitions are the statuses each status can change to. Rescheduling keeps a post scheduled with a new time.
+var postTransitions = map[string][]string{
+	models.PostDraft:     {models.PostScheduled, models.PostPublished, models.PostArchived},
+	models.PostScheduled: {models.PostDraft, models.PostScheduled, models.PostPublished},
+	models.PostPublished: {models.PostDraft, models.PostArchived},
+	models.PostArchived:  {models.PostDraft, models.PostPublished},
+}
+
+// preparePostStatus checks the status of a new post and dates it. Posts without a status are published right away,
+// or scheduled when they come with a publish_at.
+func preparePostStatus(post *models.Post, now time.Time) error {
+	if post.Status == "" {
+		post.Status = models.PostPublished
+		if post.PublishAt != nil {
+			post.Status = models.PostScheduled
+		}
+	}
+
+	// the post date is the publication, drafts carry their creation until they are published
+	post.PostDate = now
+	switch post.Status {
+	case models.PostDraft, models.PostPublished:
+		post.PublishAt = nil
+	case models.PostScheduled:
+		if post.PublishAt == nil || !post.PublishAt.After(now) {
+			return ErrPublishAtRequired
+		}
+		post.PostDate = *post.PublishAt
+	case models.PostArchived:
+		return fmt.Errorf("%w: a new post can not be archived", ErrInvalidTransition)
+	default:
+		return ErrInvalidStatus
+	}
+
+	return nil
+}
+
+// ChangePostStatus moves the post to another status. Authors change their own posts, changing someone else's needs
+// post:edit:any. Publishing dates the post now, scheduling needs publishAt and dates the post then.
+func (db *DbConnection) ChangePostStatus(principal middleware.Principal, postID, status string, publishAt *time.Time) (*models.Post, error) {
+	if _, ok := postTransitions[status]; !ok {
+		return nil, ErrInvalidStatus
+	}
+
+	post, err := db.editablePost(principal, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(postTransitions[post.Status], status) {
+		return nil, fmt.Errorf("%w: a %v post can not become %v", ErrInvalidTransition, post.Status, status)
+	}
+
+	now := time.Now()
+	changes := map[string]interface{}{"status": status}
+	switch status {
+	case models.PostDraft:
+		changes["publish_at"] = nil
+	case models.PostScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return nil, ErrPublishAtRequired
+		}
+		changes["publish_at"] = *publishAt
+		changes["post_date"] = *publishAt
+	case models.PostPublished:
+		changes["post_date"] = now
+	}
+
+	// the status is checked again, the publisher may have published the post in the meantime
+	result := db.DB.Model(&models.Post{}).Where("id=? AND status=?", post.ID, post.Status).Updates(changes)
+	if result.Error != nil {
+		db.Logger.Printf("Error, %v Occured when changing the status of the post with ID: %v", result.Error, post.ID)
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: the status of the post changed meanwhile", ErrInvalidTransition)
+	}
+
+	if err := db.DB.First(&post, "id=?", post.ID).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when searching the post with ID: %v", err, post.ID)
+		return nil, err
+	}
+
+	db.indexPost(post)
+
+	db.Logger.Printf("Changed the status of the post with ID %v to %v", post.ID, post.Status)
+	return &post, nil
+}
+
+// PublishDuePosts publishes the scheduled posts whose publish_at has passed, dated at their publish_at, and adds
+// them to the search. The update checks the status again, so several instances can run it at once.
+func (db *DbConnection) PublishDuePosts() (int64, error) {
+	due := []uuid.UUID{}
+	err := db.DB.Model(&models.Post{}).Where("status=? AND publish_at<=?", models.PostScheduled, time.Now()).Pluck("id", &due).Error
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when searching the scheduled posts", err)
+		return 0, err
+	}
+
+	if len(due) == 0 {
+		return 0, nil
+	}
+
+	result := db.DB.Model(&models.Post{}).Where("id IN ? AND status=?", due, models.PostScheduled).
+		Updates(map[string]interface{}{"status": models.PostPublished, "post_date": gorm.Expr("publish_at")})
+	if result.Error != nil {
+		db.Logger.Printf("Error, %v Occured when publishing the scheduled posts", result.Error)
+		return 0, result.Error
+	}
+
+	// another instance may have published some of them already, those are indexed here as well
+	published := []models.Post{}
+	if err := db.DB.Where("id IN ? AND status=?", due, models.PostPublished).Find(&published).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when searching the published posts", err)
+		return result.RowsAffected, err
+	}
+
+	for _, post := range published {
+		db.indexPost(post)
+	}
+
+	if result.RowsAffected > 0 {
+		db.Logger.Printf("Published %d scheduled posts", result.RowsAffected)
+	}
+
+	return result.RowsAffected, nil
+}
+
+// RunPublisher publishes the due posts every interval, it does not return
+func (db *DbConnection) RunPublisher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// a failure is logged and retried on the next tick
+		db.PublishDuePosts()
+		<-ticker.C
+	}
+}
+
+// publishedPosts are the posts the readers see
+func (db *DbConnection) publishedPosts() *gorm.DB {
+	return db.DB.Model(&models.Post{}).Where("status=?", models.PostPublished)
+}
@@ -0,0 +1,276 @@
+package repository
+
+import (
+	"blogpost/models"
+	"blogpost/search"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// the orderings of the post listing
+const (
+	SortDate      = "date"
+	SortViews     = "views"
+	SortComments  = "comments"
+	SortRelevance = "relevance"
+)
+
+var (
+	ErrInvalidSort         = errors.New("sort must be one of date, views, comments or relevance")
+	ErrRelevanceNeedsQuery = errors.New("sorting by relevance needs a search query")
+)
+
+// PostQuery filters and orders the post listing. The filters combine with AND, the values of one filter with OR.
+// Categories match regardless of case.
+type PostQuery struct {
+	// Text is a search query as understood by search.Parse
+	Text        string
+	Categories  []string
+	Authors     []uuid.UUID
+	From, To    *time.Time
+	MinViews    int
+	MinComments int
+	// Sort defaults to relevance when searching and to date otherwise, Ascending turns it around
+	Sort      string
+	Ascending bool
+}
+
+// FacetCount is how many of the listed posts have the value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facets count the posts per category, author and month of publication. Each facet applies every filter but
+// its own, so the counts show what choosing another value would return.
+type Facets struct {
+	Categories []FacetCount `json:"category"`
+	Authors    []FacetCount `json:"author"`
+	Months     []FacetCount `json:"month"`
+}
+
+// facets of the listing, they name the filter they leave out
+const (
+	facetCategory = "category"
+	facetAuthor   = "author"
+	facetMonth    = "month"
+)
+
+var postKeysets = map[string]keyset[models.Post]{
+	SortDate: postOrder,
+	SortViews: {
+		Column:  "views_count",
+		Numeric: true,
+		Key:     func(post models.Post) cursor { return cursor{Number: int64(post.ViewsCount), ID: post.ID} },
+	},
+	SortComments: {
+		Column:  "comment_count",
+		Numeric: true,
+		Key:     func(post models.Post) cursor { return cursor{Number: int64(post.CommentCount), ID: post.ID} },
+	},
+}
+
+// ListPosts returns a page of the posts matching the query along with the facet counts of all the matches.
+// Without a search the results only carry the posts.
+func (db *DbConnection) ListPosts(q PostQuery, page PageRequest) ([]SearchResult, PageInfo, *Facets, error) {
+	if q.Sort == "" {
+		q.Sort = SortDate
+		if q.Text != "" {
+			q.Sort = SortRelevance
+		}
+	}
+
+	order, ok := postKeysets[q.Sort]
+	if !ok && q.Sort != SortRelevance {
+		return nil, PageInfo{}, nil, ErrInvalidSort
+	}
+
+	if q.Sort == SortRelevance && q.Text == "" {
+		return nil, PageInfo{}, nil, ErrRelevanceNeedsQuery
+	}
+
+	var parsed search.Query
+	var hits []search.Hit
+	if q.Text != "" {
+		var err error
+		if parsed, err = search.Parse(q.Text); err != nil {
+			return nil, PageInfo{}, nil, err
+		}
+
+		if hits, err = db.Search.Search(parsed, search.MaxHits); err != nil {
+			db.Logger.Printf("Error, %v Occured when searching the posts for %q", err, q.Text)
+			return nil, PageInfo{}, nil, err
+		}
+	}
+
+	filtered := func(skip string) *gorm.DB {
+		return db.filterPosts(q, hits, skip)
+	}
+
+	var results []SearchResult
+	var info PageInfo
+	var err error
+	if q.Sort == SortRelevance {
+		results, info, err = db.rankedPosts(filtered(""), hits, parsed, q.Ascending, page)
+	} else {
+		order.Desc = !q.Ascending
+		results, info, err = db.orderedPosts(filtered(""), hits, parsed, order, page)
+	}
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when listing the posts", err)
+		return nil, info, nil, err
+	}
+
+	facets, err := db.postFacets(filtered)
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when counting the facets of the posts", err)
+		return nil, info, nil, err
+	}
+
+	db.Logger.Printf("Listed a page of the posts")
+	return results, info, facets, nil
+}
+
+// filterPosts applies the query's filters, except the one of the facet named by skip
+func (db *DbConnection) filterPosts(q PostQuery, hits []search.Hit, skip string) *gorm.DB {
+	query := db.publishedPosts()
+
+	if q.Text != "" {
+		ids := make([]uuid.UUID, 0, len(hits))
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		query = query.Where("id IN ?", ids)
+	}
+
+	if len(q.Categories) > 0 && skip != facetCategory {
+		categories := make([]string, 0, len(q.Categories))
+		for _, category := range q.Categories {
+			categories = append(categories, strings.ToLower(category))
+		}
+		query = query.Where("LOWER(category) IN ?", categories)
+	}
+
+	if len(q.Authors) > 0 && skip != facetAuthor {
+		query = query.Where("role_id IN ?", q.Authors)
+	}
+
+	if q.From != nil && skip != facetMonth {
+		query = query.Where("post_date >= ?", *q.From)
+	}
+
+	if q.To != nil && skip != facetMonth {
+		query = query.Where("post_date < ?", *q.To)
+	}
+
+	if q.MinViews > 0 {
+		query = query.Where("views_count >= ?", q.MinViews)
+	}
+
+	if q.MinComments > 0 {
+		query = query.Where("comment_count >= ?", q.MinComments)
+	}
+
+	return query
+}
+
+// orderedPosts pages through the matches in the order of a column
+func (db *DbConnection) orderedPosts(query *gorm.DB, hits []search.Hit, parsed search.Query, order keyset[models.Post], page PageRequest) ([]SearchResult, PageInfo, error) {
+	posts := []models.Post{}
+	info, err := paginate(query, page, order, &posts)
+	if err != nil {
+		return nil, info, err
+	}
+
+	scores := make(map[uuid.UUID]float64, len(hits))
+	for _, hit := range hits {
+		scores[hit.ID] = hit.Score
+	}
+
+	results := make([]SearchResult, 0, len(posts))
+	for _, post := range posts {
+		results = append(results, searchResult(post, scores[post.ID], parsed))
+	}
+
+	return results, info, nil
+}
+
+// rankedPosts pages through the matches by their score, which only exists for this request
+func (db *DbConnection) rankedPosts(query *gorm.DB, hits []search.Hit, parsed search.Query, ascending bool, page PageRequest) ([]SearchResult, PageInfo, error) {
+	posts := []models.Post{}
+	if err := query.Find(&posts).Error; err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	byID := make(map[uuid.UUID]models.Post, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+	}
+
+	// the hits are best first, the filters may have dropped some of them
+	results := make([]SearchResult, 0, len(posts))
+	for _, hit := range hits {
+		if post, ok := byID[hit.ID]; ok {
+			results = append(results, SearchResult{Post: post, Score: hit.Score})
+		}
+	}
+
+	if ascending {
+		for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+			results[i], results[j] = results[j], results[i]
+		}
+	}
+
+	results, info, err := pageSlice(results, page)
+	if err != nil {
+		return nil, info, err
+	}
+
+	for i := range results {
+		results[i] = searchResult(results[i].Post, results[i].Score, parsed)
+	}
+
+	return results, info, nil
+}
+
+// postFacets counts the matches per category, author and month
+func (db *DbConnection) postFacets(filtered func(skip string) *gorm.DB) (*Facets, error) {
+	facets := &Facets{Categories: []FacetCount{}, Authors: []FacetCount{}, Months: []FacetCount{}}
+
+	err := filtered(facetCategory).Select("category AS value, COUNT(*) AS count").
+		Group("category").Order("count desc").Order("value").Scan(&facets.Categories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = filtered(facetAuthor).Select("role_id AS value, COUNT(*) AS count").Where("role_id IS NOT NULL").
+		Group("role_id").Order("count desc").Order("value").Scan(&facets.Authors).Error
+	if err != nil {
+		return nil, err
+	}
+
+	month := db.monthExpression("post_date")
+	err = filtered(facetMonth).Select(month + " AS value, COUNT(*) AS count").
+		Group(month).Order("value desc").Scan(&facets.Months).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
+
+// monthExpression formats the timestamp column as YYYY-MM in the SQL of the database
+func (db *DbConnection) monthExpression(column string) string {
+	switch db.DB.Dialector.Name() {
+	case "mysql":
+		return "DATE_FORMAT(" + column + ", '%Y-%m')"
+	case "postgres":
+		return "to_char(" + column + ", 'YYYY-MM')"
+	}
+
+	return "strftime('%Y-%m', " + column + ")"
+}
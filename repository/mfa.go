@@ -0,0 +1,333 @@
+package repository
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"blogpost/totp"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	PurposeMFAChallenge = "mfa_challenge"
+
+	// mfaChallengeTTL is how long the second step of a login may take
+	mfaChallengeTTL   = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrInvalidMFACode    = errors.New("invalid two-factor code")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("start the two-factor enrollment first")
+	ErrMFARequiredByRole = errors.New("two-factor authentication is required for your role and can not be disabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidMFAToken   = errors.New("invalid or expired login, log in with the password again")
+	ErrUnknownRole       = errors.New("unknown role")
+)
+
+// MFARequiredError is returned by Login when the password was right but the account has two-factor authentication
+// enabled. The login is completed by LoginMFA with the Token and a code.
+type MFARequiredError struct {
+	Token string
+}
+
+func (e *MFARequiredError) Error() string {
+	return "two-factor code required"
+}
+
+// mfaChallenge issues the token that lets the second step of the login go ahead
+func (db *DbConnection) mfaChallenge(user models.User) error {
+	if user.SuspendedAt != nil {
+		db.Logger.Printf("Login of the suspended user with mailID %v refused", user.Mail)
+		return middleware.ErrAccountSuspended
+	}
+
+	token, err := db.issueUserToken(db.DB, user.ID, PurposeMFAChallenge, mfaChallengeTTL)
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when creating the two-factor challenge of the user with ID: %v", err, user.ID)
+		return err
+	}
+
+	db.Logger.Printf("User with mailID %v passed the password check, waiting for the second factor", user.Mail)
+	return &MFARequiredError{Token: token}
+}
+
+// LoginMFA completes a login with a TOTP code or a recovery code. Wrong codes count as failed logins.
+func (db *DbConnection) LoginMFA(mfaToken, code string, client Client) (*TokenPair, error) {
+	ip := client.IP
+
+	challenge := models.UserToken{}
+	err := db.DB.Where("token_hash=?", hashToken(mfaToken)).Where("purpose=?", PurposeMFAChallenge).First(&challenge).Error
+	if err != nil || challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrInvalidMFAToken
+	}
+
+	user := models.User{}
+	if err := db.DB.First(&user, "id=?", challenge.UserID).Error; err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	keys := []string{mailThrottleKey(user.Mail)}
+	if ip != "" {
+		keys = append(keys, ipThrottleKey(ip))
+	}
+
+	if err := db.checkThrottle(keys...); err != nil {
+		db.Logger.Printf("Two-factor login for mailID %v from %v refused: %v", user.Mail, ip, err)
+		return nil, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := db.checkSecondFactor(tx, user, code); err != nil {
+			return err
+		}
+
+		_, err := db.consumeUserToken(tx, mfaToken, PurposeMFAChallenge)
+		if errors.Is(err, ErrInvalidUserToken) {
+			return ErrInvalidMFAToken
+		}
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			db.Logger.Printf("Invalid two-factor code for mailID %v from %v", user.Mail, ip)
+			db.recordLoginFailure(user.Mail, ip)
+		}
+		return nil, err
+	}
+
+	if err := db.DB.Where("throttle_key=?", mailThrottleKey(user.Mail)).Delete(&models.LoginThrottle{}).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when clearing the failed logins of %v", err, user.Mail)
+	}
+
+	tokens, err := db.startSession(user, true, client)
+	if err != nil {
+		return nil, err
+	}
+
+	db.Logger.Printf("User with mailID %v logged in successfully with a second factor", user.Mail)
+	return tokens, nil
+}
+
+// checkSecondFactor accepts a TOTP code newer than the last accepted one, or an unused recovery code
+func (db *DbConnection) checkSecondFactor(tx *gorm.DB, user models.User, code string) error {
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now()); ok {
+		result := tx.Model(&models.User{}).Where("id=?", user.ID).Where("totp_last_step<?", step).Update("totp_last_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			// the code was already used
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id=?", user.ID).
+		Where("code_hash=?", hashToken(normalizeRecoveryCode(code))).
+		Where("used_at IS NULL").
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+
+	db.Logger.Printf("User with ID %v used a recovery code", user.ID)
+	return nil
+}
+
+// EnrollTOTP stores a new TOTP secret for the user and returns it with its otpauth URI.
+// The secret is only enforced once ConfirmTOTP proves the authenticator app was set up.
+func (db *DbConnection) EnrollTOTP(principal middleware.Principal) (string, string, error) {
+	user := models.User{}
+	if err := db.DB.First(&user, "id=?", principal.UserID).Error; err != nil {
+		return "", "", err
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := db.DB.Model(&models.User{}).Where("id=?", user.ID).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when enrolling the user with ID %v in two-factor authentication", err, user.ID)
+		return "", "", err
+	}
+
+	db.Logger.Printf("User with ID %v started the two-factor enrollment", user.ID)
+	return secret, totp.URI(db.Config.Accounts.TOTPIssuer, user.Mail, secret), nil
+}
+
+// ConfirmTOTP enables two-factor authentication once the user proves the app works with a code.
+// It returns the recovery codes, which are shown only this once, and a session started with the second factor.
+func (db *DbConnection) ConfirmTOTP(principal middleware.Principal, code string, client Client) ([]string, *TokenPair, error) {
+	user := models.User{}
+	if err := db.DB.First(&user, "id=?", principal.UserID).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return nil, nil, ErrMFAAlreadyEnabled
+	}
+
+	if user.TOTPSecret == "" {
+		return nil, nil, ErrMFANotEnrolled
+	}
+
+	var codes []string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return ErrInvalidMFACode
+		}
+
+		err := tx.Model(&models.User{}).Where("id=?", user.ID).Updates(map[string]interface{}{"totp_enabled_at": time.Now(), "totp_last_step": step}).Error
+		if err != nil {
+			return err
+		}
+
+		codes, err = db.replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		db.Logger.Printf("Error confirming the two-factor enrollment of the user with ID %v: %v", user.ID, err)
+		return nil, nil, err
+	}
+
+	tokens, err := db.startSession(user, true, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db.Logger.Printf("User with ID %v enabled two-factor authentication", user.ID)
+	return codes, tokens, nil
+}
+
+// DisableTOTP turns two-factor authentication off after checking a code, unless a role of the user requires it
+func (db *DbConnection) DisableTOTP(principal middleware.Principal, code string) error {
+	user := models.User{}
+	if err := db.DB.First(&user, "id=?", principal.UserID).Error; err != nil {
+		return err
+	}
+
+	if user.TOTPEnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	required, err := db.RequiresMFA(user.ID)
+	if err != nil {
+		return err
+	}
+
+	if required {
+		return ErrMFARequiredByRole
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := db.checkSecondFactor(tx, user, code); err != nil {
+			return err
+		}
+
+		err := tx.Model(&models.User{}).Where("id=?", user.ID).Updates(map[string]interface{}{"totp_secret": "", "totp_enabled_at": nil, "totp_last_step": 0}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("user_id=?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		db.Logger.Printf("Error disabling the two-factor authentication of the user with ID %v: %v", user.ID, err)
+		return err
+	}
+
+	db.Logger.Printf("User with ID %v disabled two-factor authentication", user.ID)
+	return nil
+}
+
+// RequiresMFA reports whether any role of the user requires two-factor authentication
+func (db *DbConnection) RequiresMFA(userID uuid.UUID) (bool, error) {
+	var count int64
+	err := db.DB.Model(&models.Role{}).
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id=?", userID).
+		Where("roles.require_2fa=?", true).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+// SetRoleRequires2FA changes whether the holders of the role must log in with a second factor.
+// The audit log records the role as the target.
+func (db *DbConnection) SetRoleRequires2FA(principal middleware.Principal, roleName string, required bool, ip string) error {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		role := models.Role{}
+		if err := tx.Where("name=?", roleName).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUnknownRole
+			}
+			return err
+		}
+
+		if err := tx.Model(&models.Role{}).Where("id=?", role.ID).Update("require_2fa", required).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, principal, AuditRequire2FA, &role.ID, ip, map[string]interface{}{"role": role.Name, "from": role.Require2FA, "to": required})
+	})
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when changing the two-factor policy of the role %v", err, roleName)
+		return err
+	}
+
+	db.Logger.Printf("User with ID %v set two-factor authentication required for the role %v: %v", principal.UserID, roleName, required)
+	return nil
+}
+
+// replaceRecoveryCodes deletes the recovery codes of the user and stores the hashes of new ones
+func (db *DbConnection) replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id=?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+
+		// 16 base32 characters, shown in groups of four
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))
+		code := raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+
+		recovery := models.RecoveryCode{ID: uuid.New(), UserID: userID, CodeHash: hashToken(normalizeRecoveryCode(code))}
+		if err := tx.Create(&recovery).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes in the typed code
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"blogpost/oidc"
+	"blogpost/rbac"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// oidcLoginTTL is how long the user has to log in at the provider
+const oidcLoginTTL = 10 * time.Minute
+
+var (
+	ErrOIDCDisabled         = errors.New("login with the identity provider is not configured")
+	ErrInvalidOIDCState     = errors.New("invalid or expired login, start it again")
+	ErrOIDCEmailNotVerified = errors.New("the identity provider has not verified the mail address")
+	ErrOIDCNoAccount        = errors.New("there is no account for this mail address")
+	ErrOIDCIdentityConflict = errors.New("the account is already linked to another identity at the provider")
+)
+
+// StartOIDCLogin records a new login and returns the URL of the provider to send the browser to, along with its state
+func (db *DbConnection) StartOIDCLogin(ctx context.Context) (string, string, error) {
+	if db.OIDC == nil {
+		return "", "", ErrOIDCDisabled
+	}
+
+	state, err := oidc.NewState()
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := oidc.NewState()
+	if err != nil {
+		return "", "", err
+	}
+
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+
+	// logins that were never completed are dropped on the way
+	if err := db.DB.Where("expires_at<?", now).Delete(&models.OIDCLogin{}).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when deleting the expired OIDC logins", err)
+	}
+
+	login := models.OIDCLogin{
+		ID:           uuid.New(),
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oidcLoginTTL),
+	}
+
+	if err := db.DB.Create(&login).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when recording an OIDC login", err)
+		return "", "", err
+	}
+
+	authURL, err := db.OIDC.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		db.Logger.Printf("Error starting the OIDC login: %v", err)
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// FinishOIDCLogin redeems the code the provider redirected back with and logs in the linked user,
+// linking or creating the account by its verified mail address on the first login
+func (db *DbConnection) FinishOIDCLogin(ctx context.Context, state, code string, client Client) (*TokenPair, error) {
+	if db.OIDC == nil {
+		return nil, ErrOIDCDisabled
+	}
+
+	login := models.OIDCLogin{}
+	if err := db.DB.Where("state_hash=?", hashToken(state)).First(&login).Error; err != nil {
+		return nil, ErrInvalidOIDCState
+	}
+
+	// the state works once, whatever the outcome
+	result := db.DB.Where("id=?", login.ID).Delete(&models.OIDCLogin{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 || time.Now().After(login.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+
+	identity, err := db.OIDC.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		db.Logger.Printf("Error completing the OIDC login: %v", err)
+		return nil, fmt.Errorf("error completing the login with the identity provider: %w", err)
+	}
+
+	user, err := db.oidcUser(identity, client)
+	if err != nil {
+		db.Logger.Printf("OIDC login of subject %v (%v) refused: %v", identity.Subject, identity.Email, err)
+		return nil, err
+	}
+
+	// a second factor at the provider counts, otherwise accounts with TOTP still need their code
+	if user.TOTPEnabledAt != nil && !identity.MFA {
+		return nil, db.mfaChallenge(user)
+	}
+
+	tokens, err := db.startSession(user, identity.MFA, client)
+	if err != nil {
+		return nil, err
+	}
+
+	db.Logger.Printf("User with mailID %v logged in successfully through the identity provider", user.Mail)
+	return tokens, nil
+}
+
+// oidcUser returns the user linked to the identity, linking or creating one by mail on the first login,
+// and applies the role mapping
+func (db *DbConnection) oidcUser(identity *oidc.Identity, client Client) (models.User, error) {
+	user := models.User{}
+	now := time.Now()
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		link := models.UserIdentity{}
+		err := tx.Where("issuer=?", identity.Issuer).Where("subject=?", identity.Subject).First(&link).Error
+		switch {
+		case err == nil:
+			if err := tx.First(&user, "id=?", link.UserID).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Model(&models.UserIdentity{}).Where("id=?", link.ID).Update("last_login_at", now).Error; err != nil {
+				return err
+			}
+
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := db.linkIdentity(tx, identity, &user); err != nil {
+				return err
+			}
+
+		default:
+			return err
+		}
+
+		role, ok := db.mappedRole(tx, identity)
+		if !ok || role == user.Role {
+			return nil
+		}
+
+		return db.applyMappedRole(tx, &user, role, client.IP)
+	})
+
+	return user, err
+}
+
+// applyMappedRole gives the user the role the provider maps them to. A suspended account keeps its role, its login is
+// refused anyway, and so does the last admin. The change is audited with the user as the actor.
+func (db *DbConnection) applyMappedRole(tx *gorm.DB, user *models.User, role, ip string) error {
+	if user.SuspendedAt != nil {
+		db.Logger.Printf("Role mapping of the suspended user with mailID %v skipped", user.Mail)
+		return nil
+	}
+
+	if user.Role == rbac.RoleAdmin {
+		var admins int64
+		if err := tx.Model(&models.User{}).Where("role=?", rbac.RoleAdmin).Count(&admins).Error; err != nil {
+			return err
+		}
+
+		if admins <= 1 {
+			db.Logger.Printf("The identity provider maps the last admin %v to %v, the role is kept", user.Mail, role)
+			return nil
+		}
+	}
+
+	if err := setRole(tx, *user, role); err != nil {
+		return err
+	}
+
+	actor := middleware.Principal{UserID: user.ID, Email: user.Mail, Role: user.Role}
+	details := map[string]interface{}{"from": user.Role, "to": role, "by": "identity provider"}
+	if err := recordAudit(tx, actor, AuditRoleChange, &user.ID, ip, details); err != nil {
+		return err
+	}
+
+	db.Logger.Printf("Role of the user with mailID %v changed from %v to %v by the identity provider", user.Mail, user.Role, role)
+	user.Role = role
+	return nil
+}
+
+// linkIdentity links the identity to the account with its mail address, creating the account when allowed.
+// Accounts are only matched on a mail address the provider has verified.
+func (db *DbConnection) linkIdentity(tx *gorm.DB, identity *oidc.Identity, user *models.User) error {
+	if identity.Email == "" || !identity.EmailVerified {
+		return ErrOIDCEmailNotVerified
+	}
+
+	now := time.Now()
+	err := tx.First(user, "mail=?", identity.Email).Error
+	switch {
+	case err == nil:
+		var linked int64
+		if err := tx.Model(&models.UserIdentity{}).Where("user_id=?", user.ID).Where("issuer=?", identity.Issuer).Count(&linked).Error; err != nil {
+			return err
+		}
+
+		if linked > 0 {
+			return ErrOIDCIdentityConflict
+		}
+
+		// the provider vouches for the address
+		if user.EmailVerifiedAt == nil {
+			if err := tx.Model(&models.User{}).Where("id=?", user.ID).Update("email_verified_at", now).Error; err != nil {
+				return err
+			}
+			user.EmailVerifiedAt = &now
+		}
+
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if !db.Config.OIDC.AutoCreate {
+			return ErrOIDCNoAccount
+		}
+
+		role := db.Config.OIDC.DefaultRole
+		if !db.roleExists(tx, role) {
+			return fmt.Errorf("the default role %q of the identity provider does not exist", role)
+		}
+
+		// without a password the account logs in through the provider only, until one is set with a password reset
+		*user = models.User{ID: uuid.New(), Role: role, Mail: identity.Email, EmailVerifiedAt: &now}
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		if err := assignRole(tx, user.ID, role); err != nil {
+			return err
+		}
+		db.Logger.Printf("Created the user with mailID %v for the identity provider", user.Mail)
+
+	default:
+		return err
+	}
+
+	link := models.UserIdentity{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		Issuer:      identity.Issuer,
+		Subject:     identity.Subject,
+		CreatedAt:   now,
+		LastLoginAt: &now,
+	}
+
+	if err := tx.Create(&link).Error; err != nil {
+		return err
+	}
+
+	db.Logger.Printf("Linked the user with mailID %v to subject %v at %v", user.Mail, identity.Subject, identity.Issuer)
+	return nil
+}
+
+// mappedRole returns the role the role mapping gives the first matching value of the role claim
+func (db *DbConnection) mappedRole(tx *gorm.DB, identity *oidc.Identity) (string, bool) {
+	if db.Config.OIDC.RoleClaim == "" {
+		return "", false
+	}
+
+	for _, value := range oidc.ClaimValues(identity.Claims, db.Config.OIDC.RoleClaim) {
+		role, ok := db.Config.OIDC.RoleMapping[value]
+		if !ok {
+			continue
+		}
+
+		if !db.roleExists(tx, role) {
+			db.Logger.Printf("The role mapping of %v names the unknown role %v", value, role)
+			continue
+		}
+
+		return role, true
+	}
+
+	return "", false
+}
+
+func (db *DbConnection) roleExists(tx *gorm.DB, name string) bool {
+	var count int64
+	if err := tx.Model(&models.Role{}).Where("name=?", name).Count(&count).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when looking up the role %v", err, name)
+		return false
+	}
+
+	return count > 0
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageRequest asks for the page after the cursor, or before it for a previous cursor. An empty cursor is the first page.
+type PageRequest struct {
+	Cursor string
+	Limit  int
+}
+
+// PageInfo describes the returned page, the cursors are only set when there is a page in that direction
+type PageInfo struct {
+	Limit      int    `json:"limit"`
+	Count      int    `json:"count"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	HasNext    bool   `json:"has_next"`
+	HasPrev    bool   `json:"has_prev"`
+}
+
+// cursor is the position of a row in the ordering, encoded opaquely for the clients.
+// Listings ordered by the database use the row's time or number and its ID, rankings computed per request the offset.
+type cursor struct {
+	// Column is the ordering the cursor belongs to
+	Column string    `json:"c,omitempty"`
+	Time   time.Time `json:"t"`
+	Number int64     `json:"n,omitempty"`
+	ID     uuid.UUID `json:"id"`
+	// Before is set on previous cursors, the page ends right before the row
+	Before bool `json:"b,omitempty"`
+	Offset int  `json:"o,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(encoded string) (cursor, error) {
+	c := cursor{}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(raw, &c); err != nil || c.Offset < 0 {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// limit is the requested page size within the allowed range
+func (p PageRequest) limit() int {
+	if p.Limit < 1 {
+		return DefaultPageLimit
+	}
+
+	if p.Limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+
+	return p.Limit
+}
+
+// keyset orders the rows of a listing by a timestamp or integer column with the ID breaking ties, so pages stay stable
+// while rows are added. Key returns the position of a row, its Time or for a Numeric column its Number.
+type keyset[T any] struct {
+	Column  string
+	Desc    bool
+	Numeric bool
+	Key     func(T) cursor
+}
+
+// paginate reads the requested page of the query into rows
+func paginate[T any](query *gorm.DB, page PageRequest, order keyset[T], rows *[]T) (PageInfo, error) {
+	page.Limit = page.limit()
+	info := PageInfo{Limit: page.Limit}
+
+	var after *cursor
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return info, err
+		}
+
+		if c.ID == uuid.Nil || c.Column != order.Column {
+			return info, ErrInvalidCursor
+		}
+		after = &c
+	}
+
+	// a previous page is read backwards from the cursor and turned around afterwards
+	backwards := after != nil && after.Before
+	desc := order.Desc != backwards
+
+	direction, comparison := "asc", ">"
+	if desc {
+		direction, comparison = "desc", "<"
+	}
+
+	if after != nil {
+		var value interface{} = after.Time
+		if order.Numeric {
+			value = after.Number
+		}
+		query = query.Where(fmt.Sprintf("(%[1]s %[2]s ?) OR (%[1]s = ? AND id %[2]s ?)", order.Column, comparison), value, value, after.ID)
+	}
+
+	err := query.Order(fmt.Sprintf("%s %s", order.Column, direction)).Order("id " + direction).Limit(page.Limit + 1).Find(rows).Error
+	if err != nil {
+		return info, err
+	}
+
+	more := len(*rows) > page.Limit
+	if more {
+		*rows = (*rows)[:page.Limit]
+	}
+
+	if backwards {
+		for i, j := 0, len(*rows)-1; i < j; i, j = i+1, j-1 {
+			(*rows)[i], (*rows)[j] = (*rows)[j], (*rows)[i]
+		}
+		info.HasPrev, info.HasNext = more, true
+	} else {
+		info.HasNext, info.HasPrev = more, after != nil
+	}
+
+	info.Count = len(*rows)
+	if info.Count == 0 {
+		// the rows around the cursor were deleted, or there are none
+		info.HasNext, info.HasPrev = false, false
+		return info, nil
+	}
+
+	if info.HasNext {
+		next := order.Key((*rows)[info.Count-1])
+		next.Column = order.Column
+		info.NextCursor = encodeCursor(next)
+	}
+
+	if info.HasPrev {
+		prev := order.Key((*rows)[0])
+		prev.Column, prev.Before = order.Column, true
+		info.PrevCursor = encodeCursor(prev)
+	}
+
+	return info, nil
+}
+
+// pageSlice returns the requested page of rows already ranked in memory
+func pageSlice[T any](rows []T, page PageRequest) ([]T, PageInfo, error) {
+	info := PageInfo{Limit: page.limit()}
+
+	offset := 0
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, info, err
+		}
+
+		if c.ID != uuid.Nil {
+			return nil, info, ErrInvalidCursor
+		}
+		offset = c.Offset
+	}
+
+	if offset > len(rows) {
+		offset = len(rows)
+	}
+
+	end := offset + info.Limit
+	if end > len(rows) {
+		end = len(rows)
+	}
+
+	info.Count = end - offset
+	info.HasNext = end < len(rows)
+	info.HasPrev = offset > 0 && info.Count > 0
+
+	if info.HasNext {
+		info.NextCursor = encodeCursor(cursor{Offset: end})
+	}
+
+	if info.HasPrev {
+		prev := offset - info.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		info.PrevCursor = encodeCursor(cursor{Offset: prev})
+	}
+
+	return rows[offset:end], info, nil
+}
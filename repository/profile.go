@@ -0,0 +1,336 @@
+package repository
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"blogpost/rbac"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const PurposeEmailChange = "email_change"
+
+// recentLogin is how long after logging in an account without a password may be deleted, logging in again through
+// the identity provider stands in for the password
+const recentLogin = 10 * time.Minute
+
+var (
+	ErrWrongPassword   = errors.New("the current password is wrong")
+	ErrNoPassword      = errors.New("the account has no password yet, set one with a password reset first")
+	ErrMailInUse       = errors.New("the mail address is already in use")
+	ErrSameMail        = errors.New("the new mail address is the current one")
+	ErrLastAdmin       = errors.New("the last admin account can not be deleted")
+	ErrNoPasswordGiven = errors.New("current_password is required")
+	ErrLoginNotRecent  = errors.New("the account has no password, log in again with single sign-on and delete it within 10 minutes")
+)
+
+// Profile is what a user sees of their own account
+type Profile struct {
+	ID               uuid.UUID             `json:"id"`
+	Mail             string                `json:"mail"`
+	PendingMail      string                `json:"pending_mail,omitempty"`
+	EmailVerifiedAt  *time.Time            `json:"email_verified_at"`
+	Role             string                `json:"role"`
+	Permissions      []string              `json:"permissions"`
+	HasPassword      bool                  `json:"has_password"`
+	TwoFactorEnabled bool                  `json:"two_factor_enabled"`
+	Identities       []models.UserIdentity `json:"identities"`
+}
+
+// Profile returns the account of the caller
+func (db *DbConnection) Profile(principal middleware.Principal) (*Profile, error) {
+	user := models.User{}
+	if err := db.DB.First(&user, "id=?", principal.UserID).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when searching the user with ID: %v", err, principal.UserID)
+		return nil, err
+	}
+
+	permissions, err := db.Permissions(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	identities := []models.UserIdentity{}
+	if err := db.DB.Where("user_id=?", user.ID).Find(&identities).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when searching the identities of the user with ID: %v", err, user.ID)
+		return nil, err
+	}
+
+	return &Profile{
+		ID:               user.ID,
+		Mail:             user.Mail,
+		PendingMail:      user.PendingMail,
+		EmailVerifiedAt:  user.EmailVerifiedAt,
+		Role:             user.Role,
+		Permissions:      permissions,
+		HasPassword:      user.Password != "",
+		TwoFactorEnabled: user.TOTPEnabledAt != nil,
+		Identities:       identities,
+	}, nil
+}
+
+// checkCurrentPassword confirms a sensitive change with the password, wrong passwords count as failed logins
+func (db *DbConnection) checkCurrentPassword(user models.User, password, ip string) error {
+	if user.Password == "" {
+		return ErrNoPassword
+	}
+
+	keys := []string{mailThrottleKey(user.Mail)}
+	if ip != "" {
+		keys = append(keys, ipThrottleKey(ip))
+	}
+
+	if err := db.checkThrottle(keys...); err != nil {
+		return err
+	}
+
+	valid, _, err := db.Hasher.Verify(password, user.Password)
+	if err != nil {
+		db.Logger.Printf("Error verifying the password of mailID %v: %v", user.Mail, err)
+	}
+
+	if !valid {
+		db.Logger.Printf("Wrong current password for mailID %v from %v", user.Mail, ip)
+		db.recordLoginFailure(user.Mail, ip)
+		return ErrWrongPassword
+	}
+
+	return nil
+}
+
+// checkRecentLogin confirms a sensitive change of an account without a password: the session of the request must
+// have started within recentLogin, such accounts only log in through their identity provider
+func (db *DbConnection) checkRecentLogin(principal middleware.Principal) error {
+	session := models.Session{}
+	err := db.DB.Where("id=? AND user_id=?", principal.SessionID, principal.UserID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrLoginNotRecent
+	}
+
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when searching the session with ID: %v", err, principal.SessionID)
+		return err
+	}
+
+	if time.Since(session.CreatedAt) > recentLogin {
+		return ErrLoginNotRecent
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the password of the caller after checking the current one.
+// All sessions are revoked and a new one is started for the caller.
+func (db *DbConnection) ChangePassword(principal middleware.Principal, current, password string, client Client) (*TokenPair, error) {
+	user := models.User{}
+	if err := db.DB.First(&user, "id=?", principal.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.checkCurrentPassword(user, current, client.IP); err != nil {
+		return nil, err
+	}
+
+	if err := db.Policy.Check(password, user.Mail); err != nil {
+		return nil, err
+	}
+
+	hash, err := db.Hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id=?", user.ID).Update("password", hash).Error; err != nil {
+			return err
+		}
+
+		return revokeSessions(tx, user.ID)
+	})
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when changing the password of the user with ID: %v", err, user.ID)
+		return nil, err
+	}
+
+	db.Logger.Printf("User with ID %v changed the password, all the sessions were revoked", user.ID)
+	return db.startSession(user, principal.MFA, client)
+}
+
+// RequestEmailChange records the new address and issues the token that confirms it.
+// The current address stays in use until the link mailed to the new one is opened.
+func (db *DbConnection) RequestEmailChange(principal middleware.Principal, mail, current, ip string) (*models.User, string, error) {
+	mail = strings.TrimSpace(mail)
+
+	user := models.User{}
+	if err := db.DB.First(&user, "id=?", principal.UserID).Error; err != nil {
+		return nil, "", err
+	}
+
+	if strings.EqualFold(mail, user.Mail) {
+		return nil, "", ErrSameMail
+	}
+
+	if err := db.checkCurrentPassword(user, current, ip); err != nil {
+		return nil, "", err
+	}
+
+	var taken int64
+	if err := db.DB.Model(&models.User{}).Where("mail=?", mail).Count(&taken).Error; err != nil {
+		return nil, "", err
+	}
+
+	if taken > 0 {
+		return nil, "", ErrMailInUse
+	}
+
+	var token string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id=?", user.ID).Update("pending_mail", mail).Error; err != nil {
+			return err
+		}
+
+		var err error
+		token, err = db.issueUserToken(tx, user.ID, PurposeEmailChange, db.Config.Accounts.VerificationTTL)
+		return err
+	})
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when requesting the mail change of the user with ID: %v", err, user.ID)
+		return nil, "", err
+	}
+
+	user.PendingMail = mail
+	db.Logger.Printf("User with ID %v requested to change the mail to %v", user.ID, mail)
+	return &user, token, nil
+}
+
+// ConfirmEmailChange switches the user the token was issued to over to the pending address, which is verified by the link
+func (db *DbConnection) ConfirmEmailChange(token string) error {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		userToken, err := db.consumeUserToken(tx, token, PurposeEmailChange)
+		if err != nil {
+			return err
+		}
+
+		user := models.User{}
+		if err := tx.First(&user, "id=?", userToken.UserID).Error; err != nil {
+			return err
+		}
+
+		if user.PendingMail == "" {
+			return ErrInvalidUserToken
+		}
+
+		// the address may have been taken since the change was requested
+		var taken int64
+		if err := tx.Model(&models.User{}).Where("mail=?", user.PendingMail).Where("id<>?", user.ID).Count(&taken).Error; err != nil {
+			return err
+		}
+
+		if taken > 0 {
+			return ErrMailInUse
+		}
+
+		return tx.Model(&models.User{}).Where("id=?", user.ID).Updates(map[string]interface{}{
+			"mail":              user.PendingMail,
+			"pending_mail":      "",
+			"email_verified_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		db.Logger.Printf("Error confirming the mail change: %v", err)
+		return err
+	}
+
+	db.Logger.Printf("Mail change confirmed")
+	return nil
+}
+
+// DeleteAccount deletes the caller's account after checking the password. Accounts without a password, which only
+// log in through an identity provider, must have logged in recently instead. Their comments and views are deleted,
+// their posts stay published without an author so the discussions under them survive.
+func (db *DbConnection) DeleteAccount(principal middleware.Principal, current, ip string) error {
+	user := models.User{}
+	if err := db.DB.First(&user, "id=?", principal.UserID).Error; err != nil {
+		return err
+	}
+
+	if user.Password == "" {
+		if err := db.checkRecentLogin(principal); err != nil {
+			return err
+		}
+	} else {
+		if current == "" {
+			return ErrNoPasswordGiven
+		}
+
+		if err := db.checkCurrentPassword(user, current, ip); err != nil {
+			return err
+		}
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if user.Role == rbac.RoleAdmin {
+			var admins int64
+			if err := tx.Model(&models.User{}).Where("role=?", rbac.RoleAdmin).Count(&admins).Error; err != nil {
+				return err
+			}
+
+			if admins <= 1 {
+				return ErrLastAdmin
+			}
+		}
+
+		postIDs := []uuid.UUID{}
+		if err := tx.Model(&models.Comments{}).Where("role_id=?", user.ID).Distinct("post_id").Pluck("post_id", &postIDs).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("role_id=?", user.ID).Delete(&models.Comments{}).Error; err != nil {
+			return err
+		}
+
+		for _, postID := range postIDs {
+			var commentCount int64
+			if err := tx.Model(&models.Comments{}).Where("post_id=?", postID).Count(&commentCount).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Model(&models.Post{}).Where("id=?", postID).Update("comment_count", commentCount).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("role_id=?", user.ID).Delete(&models.Views{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Post{}).Where("role_id=?", user.ID).Update("role_id", nil).Error; err != nil {
+			return err
+		}
+
+		// the sessions, tokens, recovery codes, API keys, identities and roles of the user are deleted here rather
+		// than left to the foreign keys
+		owned := []interface{}{
+			&models.Session{}, &models.RefreshToken{}, &models.UserToken{}, &models.RecoveryCode{}, &models.APIKey{},
+			&models.UserIdentity{}, &models.UserRole{},
+		}
+		for _, model := range owned {
+			if err := tx.Where("user_id=?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("id=?", user.ID).Delete(&models.User{}).Error
+	})
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when deleting the user with ID: %v", err, user.ID)
+		return err
+	}
+
+	db.Logger.Printf("User with ID %v deleted their account", user.ID)
+	return nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"blogpost/rbac"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Permissions returns the names of the permissions granted to the user through all of their roles
+func (db *DbConnection) Permissions(userID uuid.UUID) ([]string, error) {
+	permissions := make([]string, 0)
+
+	err := db.DB.Model(&models.Permission{}).
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id=?", userID).
+		Pluck("permissions.name", &permissions).Error
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when retriving the permissions of the user with ID: %v", err, userID)
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// HasPermission reports whether the user holds the permission through any of their roles
+func (db *DbConnection) HasPermission(userID uuid.UUID, permission string) (bool, error) {
+	permissions, err := db.Permissions(userID)
+	if err != nil {
+		return false, err
+	}
+
+	return rbac.Has(permissions, permission), nil
+}
+
+// principalCan reports whether the caller may use the permission: the user must hold it and,
+// for API keys, it must be in the key's scopes
+func (db *DbConnection) principalCan(principal middleware.Principal, permission string) (bool, error) {
+	if !principal.Allows(permission) {
+		return false, nil
+	}
+
+	return db.HasPermission(principal.UserID, permission)
+}
+
+// assignRole gives the user the named role, tx is the transaction the user is created in
+func assignRole(tx *gorm.DB, userID uuid.UUID, roleName string) error {
+	role := models.Role{}
+	if err := tx.Where("name=?", roleName).First(&role).Error; err != nil {
+		return fmt.Errorf("unknown role %q", roleName)
+	}
+
+	return tx.Where(models.UserRole{UserID: userID, RoleID: role.ID}).FirstOrCreate(&models.UserRole{UserID: userID, RoleID: role.ID}).Error
+}
+
+// setRole moves the user from their current role to the named one, other roles they hold are kept
+func setRole(tx *gorm.DB, user models.User, roleName string) error {
+	if err := tx.Model(&models.User{}).Where("id=?", user.ID).Update("role", roleName).Error; err != nil {
+		return err
+	}
+
+	previous := models.Role{}
+	err := tx.Where("name=?", user.Role).First(&previous).Error
+	if err == nil {
+		if err := tx.Where("user_id=?", user.ID).Where("role_id=?", previous.ID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return assignRole(tx, user.ID, roleName)
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"blogpost/diff"
+	"blogpost/middleware"
+	"blogpost/models"
+	"blogpost/rbac"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// revisions are listed newest first
+var revisionOrder = keyset[models.PostRevision]{
+	Column:  "number",
+	Desc:    true,
+	Numeric: true,
+	Key: func(revision models.PostRevision) cursor {
+		return cursor{Number: int64(revision.Number), ID: revision.ID}
+	},
+}
+
+// FieldDiff compares one field of two revisions
+type FieldDiff struct {
+	Field   string    `json:"field"`
+	Changed bool      `json:"changed"`
+	Changes []diff.Op `json:"changes"`
+}
+
+// RevisionDiff compares the title, description and category of two revisions of a post
+type RevisionDiff struct {
+	PostID uuid.UUID   `json:"post_id"`
+	From   int         `json:"from"`
+	To     int         `json:"to"`
+	Fields []FieldDiff `json:"fields"`
+}
+
+// editablePost loads the post if the principal may edit it: authors edit their own posts, editing someone else's
+// needs post:edit:any. It is called outside of transactions, sqlite has a single connection.
+func (db *DbConnection) editablePost(principal middleware.Principal, postID string) (models.Post, error) {
+	post := models.Post{}
+
+	query := db.DB.Where("id=?", postID)
+	canEditAny, err := db.principalCan(principal, rbac.PostEditAny)
+	if err != nil {
+		return post, err
+	}
+
+	if !canEditAny {
+		query = query.Where("role_id=?", principal.UserID)
+	}
+
+	if err := query.First(&post).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return post, ErrPostNotFound
+		}
+		db.Logger.Printf("Error, %v Occured when searching the post with ID: %v", err, postID)
+		return post, err
+	}
+
+	return post, nil
+}
+
+// addRevision records the content of the post as its next revision
+func addRevision(tx *gorm.DB, post models.Post, authorID uuid.UUID, restoredFrom *int) (*models.PostRevision, error) {
+	var last int
+	if err := tx.Model(&models.PostRevision{}).Where("post_id=?", post.ID).Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+		return nil, err
+	}
+
+	revision := models.PostRevision{
+		ID:           uuid.New(),
+		PostID:       post.ID,
+		Number:       last + 1,
+		AuthorID:     authorID,
+		Category:     post.Category,
+		Title:        post.Title,
+		Description:  post.Description,
+		RestoredFrom: restoredFrom,
+		CreatedAt:    time.Now(),
+	}
+
+	// the unique number makes a concurrent edit fail instead of both writing the same revision
+	if err := tx.Create(&revision).Error; err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+// contentChanged tells whether the post differs from its latest revision, a post without revisions has changed
+func contentChanged(tx *gorm.DB, post models.Post) (bool, error) {
+	latest := models.PostRevision{}
+	err := tx.Where("post_id=?", post.ID).Order("number desc").Limit(1).Find(&latest).Error
+	if err != nil {
+		return false, err
+	}
+
+	return latest.ID == uuid.Nil || latest.Title != post.Title || latest.Description != post.Description ||
+		latest.Category != post.Category, nil
+}
+
+func revisionOf(tx *gorm.DB, postID uuid.UUID, number int) (models.PostRevision, error) {
+	revision := models.PostRevision{}
+	if err := tx.Where("post_id=? AND number=?", postID, number).First(&revision).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return revision, ErrRevisionNotFound
+		}
+		return revision, err
+	}
+
+	return revision, nil
+}
+
+// ListRevisions returns a page of the revisions of the post, newest first
+func (db *DbConnection) ListRevisions(principal middleware.Principal, postID string, page PageRequest, revisions *[]models.PostRevision) (PageInfo, error) {
+	post, err := db.editablePost(principal, postID)
+	if err != nil {
+		return PageInfo{}, err
+	}
+
+	info, err := paginate(db.DB.Model(&models.PostRevision{}).Where("post_id=?", post.ID), page, revisionOrder, revisions)
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when listing the revisions of the post with ID: %v", err, post.ID)
+		return info, err
+	}
+
+	db.Logger.Printf("Retrived the revisions of the post with ID: %v", post.ID)
+	return info, nil
+}
+
+// DiffRevisions compares two revisions of the post word by word. Without to it is the latest revision,
+// without from the one before to. The first revision is compared with an empty post, as from 0.
+func (db *DbConnection) DiffRevisions(principal middleware.Principal, postID string, from, to int) (*RevisionDiff, error) {
+	post, err := db.editablePost(principal, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	if to == 0 {
+		if err := db.DB.Model(&models.PostRevision{}).Where("post_id=?", post.ID).Select("COALESCE(MAX(number), 0)").Scan(&to).Error; err != nil {
+			db.Logger.Printf("Error, %v Occured when searching the revisions of the post with ID: %v", err, post.ID)
+			return nil, err
+		}
+	}
+
+	if from == 0 {
+		from = to - 1
+	}
+
+	// the first revision has nothing before it, all of its content is added
+	older := models.PostRevision{}
+	if from > 0 {
+		if older, err = revisionOf(db.DB, post.ID, from); err != nil {
+			return nil, err
+		}
+	}
+
+	newer, err := revisionOf(db.DB, post.ID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	compared := &RevisionDiff{PostID: post.ID, From: from, To: to}
+	fields := []struct {
+		name          string
+		before, after string
+	}{
+		{"title", older.Title, newer.Title},
+		{"description", older.Description, newer.Description},
+		{"category", older.Category, newer.Category},
+	}
+	for _, field := range fields {
+		compared.Fields = append(compared.Fields, FieldDiff{
+			Field:   field.name,
+			Changed: field.before != field.after,
+			Changes: diff.Words(field.before, field.after),
+		})
+	}
+
+	db.Logger.Printf("Compared the revisions %d and %d of the post with ID: %v", from, to, post.ID)
+	return compared, nil
+}
+
+// RestoreRevision puts the content of an older revision back into the post, recorded as a new revision
+func (db *DbConnection) RestoreRevision(principal middleware.Principal, postID string, number int) (*models.Post, *models.PostRevision, error) {
+	post, err := db.editablePost(principal, postID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var restored *models.PostRevision
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		revision, err := revisionOf(tx, post.ID, number)
+		if err != nil {
+			return err
+		}
+
+		content := map[string]interface{}{"category": revision.Category, "title": revision.Title, "description": revision.Description}
+		if err := tx.Model(&models.Post{}).Where("id=?", post.ID).Updates(content).Error; err != nil {
+			return err
+		}
+
+		if err := tx.First(&post, "id=?", post.ID).Error; err != nil {
+			return err
+		}
+
+		restored, err = addRevision(tx, post, principal.UserID, &revision.Number)
+		return err
+	})
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when restoring the revision %d of the post with ID: %v", err, number, postID)
+		return nil, nil, err
+	}
+
+	db.indexPost(post)
+
+	db.Logger.Printf("Restored the revision %d of the post with ID %v as revision %d", number, post.ID, restored.Number)
+	return &post, restored, nil
+}
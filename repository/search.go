@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"blogpost/config"
+	"blogpost/models"
+	"blogpost/search"
+	"log"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// snippetWords is the length of the description excerpt of a search result
+const snippetWords = 30
+
+// SearchResult is a post matching a search, with the matching words of its title and description marked
+type SearchResult struct {
+	Post    models.Post `json:"post"`
+	Score   float64     `json:"score,omitempty"`
+	Title   string      `json:"title_highlight,omitempty"`
+	Snippet string      `json:"snippet,omitempty"`
+}
+
+// NewSearchBackend returns the configured search backend. The in-process index is filled with the published posts
+// here, so it is ready before the first request.
+func NewSearchBackend(db *gorm.DB, cfg config.SearchConfig, logger *log.Logger) (search.Backend, error) {
+	if cfg.Backend != "memory" {
+		backend, err := search.NewDatabase(db)
+		if err == nil || cfg.Backend == "database" {
+			return backend, err
+		}
+	}
+
+	index := search.NewIndex()
+	posts := []models.Post{}
+	err := db.Model(&models.Post{}).Select("id, title, description").Where("status=?", models.PostPublished).Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		if err := index.Index(searchDocument(post)); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Printf("Indexed %d posts for the search", len(posts))
+	return index, nil
+}
+
+func searchDocument(post models.Post) search.Document {
+	return search.Document{ID: post.ID, Title: post.Title, Description: post.Description}
+}
+
+// indexPost brings the search up to date with the post, only published posts are searched and the others are taken
+// out. A failure only affects the search, so it is logged.
+func (db *DbConnection) indexPost(post models.Post) {
+	if post.Status != models.PostPublished {
+		db.unindexPost(post.ID)
+		return
+	}
+
+	if err := db.Search.Index(searchDocument(post)); err != nil {
+		db.Logger.Printf("Error, %v Occured when indexing the post with ID: %v", err, post.ID)
+	}
+}
+
+func (db *DbConnection) unindexPost(postID uuid.UUID) {
+	if err := db.Search.Remove(postID); err != nil {
+		db.Logger.Printf("Error, %v Occured when removing the post with ID %v from the search", err, postID)
+	}
+}
+
+// searchResult marks the matches of the search in the post, without a search it only carries the post
+func searchResult(post models.Post, score float64, query search.Query) SearchResult {
+	result := SearchResult{Post: post, Score: score}
+	if len(query.Terms) > 0 {
+		result.Title = search.Highlight(post.Title, query)
+		result.Snippet = search.Snippet(post.Description, query, snippetWords)
+	}
+
+	return result
+}
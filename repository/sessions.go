@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// sessionSeenInterval limits how often the last use of a session is written
+const sessionSeenInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Client describes the device a login comes from, it is recorded on the session
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// SessionSummary is what a user sees of one of their sessions
+type SessionSummary struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	MFA        bool      `json:"mfa"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// ListSessions returns the active sessions of the caller, most recently used first, marking the one the caller uses
+func (db *DbConnection) ListSessions(principal middleware.Principal) ([]SessionSummary, error) {
+	sessions := []models.Session{}
+	err := db.DB.Where("user_id=?", principal.UserID).
+		Where("revoked_at IS NULL").
+		Where("expires_at>?", time.Now()).
+		Order("last_seen_at desc").
+		Find(&sessions).Error
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when listing the sessions of the user with ID: %v", err, principal.UserID)
+		return nil, err
+	}
+
+	summaries := make([]SessionSummary, 0, len(sessions))
+	for _, session := range sessions {
+		summaries = append(summaries, SessionSummary{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			MFA:        session.MFA,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == principal.SessionID,
+		})
+	}
+
+	return summaries, nil
+}
+
+// RevokeSession signs one of the caller's sessions out, its tokens stop working straight away
+func (db *DbConnection) RevokeSession(principal middleware.Principal, sessionID string) error {
+	session := models.Session{}
+	err := db.DB.Where("id=?", sessionID).Where("user_id=?", principal.UserID).Where("revoked_at IS NULL").First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		db.Logger.Printf("Error, %v Occured when searching the session with ID: %v", err, sessionID)
+		return err
+	}
+
+	if err := db.revokeFamily(session.ID); err != nil {
+		db.Logger.Printf("Error, %v Occured when revoking the session: %v", err, session.ID)
+		return err
+	}
+
+	db.Logger.Printf("User with ID %v revoked the session with ID %v", principal.UserID, session.ID)
+	return nil
+}
+
+// touchSession records the use of a session, at most once per sessionSeenInterval
+func (db *DbConnection) touchSession(session models.Session) {
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) < sessionSeenInterval {
+		return
+	}
+
+	if err := db.DB.Model(&models.Session{}).Where("id=?", session.ID).Update("last_seen_at", now).Error; err != nil {
+		db.Logger.Printf("Error, %v Occured when recording the use of the session: %v", err, session.ID)
+	}
+}
+
+// revokeSessions revokes every session of the user and their refresh tokens within the transaction
+func revokeSessions(tx *gorm.DB, userID uuid.UUID) error {
+	now := time.Now()
+	if err := tx.Model(&models.Session{}).Where("user_id=?", userID).Where("revoked_at IS NULL").Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.RefreshToken{}).Where("user_id=?", userID).Where("revoked_at IS NULL").Update("revoked_at", now).Error
+}
+
+// revokeSession revokes the session and its refresh token family within the transaction
+func revokeSession(tx *gorm.DB, sessionID uuid.UUID) error {
+	now := time.Now()
+	if err := tx.Model(&models.Session{}).Where("id=?", sessionID).Where("revoked_at IS NULL").Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.RefreshToken{}).Where("family_id=?", sessionID).Where("revoked_at IS NULL").Update("revoked_at", now).Error
+}
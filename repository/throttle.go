@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidCredentials is returned for an unknown mail and a wrong password alike
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ThrottledError is returned while an account or client address is blocked after failed logins
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+func mailThrottleKey(mail string) string {
+	return "mail:" + strings.ToLower(strings.TrimSpace(mail))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// checkThrottle fails with a ThrottledError when any of the keys is blocked
+func (db *DbConnection) checkThrottle(keys ...string) error {
+	throttles := []models.LoginThrottle{}
+	if err := db.DB.Where("throttle_key IN ?", keys).Find(&throttles).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	for _, throttle := range throttles {
+		if throttle.BlockedUntil != nil && throttle.BlockedUntil.After(now) && throttle.BlockedUntil.Sub(now) > wait {
+			wait = throttle.BlockedUntil.Sub(now)
+		}
+	}
+
+	if wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+
+	return nil
+}
+
+// recordFailure counts a failed login against the key. Once max failures are reached the key is locked for
+// the lockout duration, before that a progressive delay doubles with every failure when progressive is set.
+func (db *DbConnection) recordFailure(key string, max int, progressive bool) error {
+	lockout := db.Config.Accounts.LockoutDuration
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginThrottle{Key: key}).Error; err != nil {
+			return err
+		}
+
+		throttle := models.LoginThrottle{}
+		if err := tx.Where("throttle_key=?", key).First(&throttle).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		// failures older than the lockout are forgotten
+		if now.Sub(throttle.LastFailureAt) > lockout {
+			throttle.Failures = 0
+		}
+		throttle.Failures++
+
+		var delay time.Duration
+		switch {
+		case throttle.Failures >= max:
+			delay = lockout
+			db.Logger.Printf("Locked %v for %v after %d failed logins", key, lockout, throttle.Failures)
+		case progressive && throttle.Failures > 1:
+			// the first failure is free, then 1s, 2s, 4s...
+			delay = lockout
+			if shift := throttle.Failures - 2; shift < 30 && time.Second<<shift < lockout {
+				delay = time.Second << shift
+			}
+		}
+
+		blockedUntil := now.Add(delay)
+		return tx.Model(&models.LoginThrottle{}).Where("throttle_key=?", key).Updates(map[string]interface{}{
+			"failures":        throttle.Failures,
+			"last_failure_at": now,
+			"blocked_until":   blockedUntil,
+		}).Error
+	})
+}
+
+// recordLoginFailure counts the failure against both the account and the client address
+func (db *DbConnection) recordLoginFailure(mail, ip string) {
+	if err := db.recordFailure(mailThrottleKey(mail), db.Config.Accounts.MaxLoginFailures, true); err != nil {
+		db.Logger.Printf("Error, %v Occured when recording a failed login", err)
+	}
+
+	if ip == "" {
+		return
+	}
+
+	if err := db.recordFailure(ipThrottleKey(ip), db.Config.Accounts.MaxIPLoginFailures, false); err != nil {
+		db.Logger.Printf("Error, %v Occured when recording a failed login", err)
+	}
+}
+
+// UnlockAccount clears the failed logins of the user with the mail
+func (db *DbConnection) UnlockAccount(principal middleware.Principal, mail, ip string) error {
+	user := models.User{}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("mail=?", mail).First(&user).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("throttle_key=?", mailThrottleKey(user.Mail)).Delete(&models.LoginThrottle{}).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, principal, AuditUnlock, &user.ID, ip, nil)
+	})
+	if err != nil {
+		db.Logger.Printf("Error, %v Occured when unlocking the account of %v", err, mail)
+		return err
+	}
+
+	db.Logger.Printf("User with ID %v unlocked the account of the user with ID: %v", principal.UserID, user.ID)
+	return nil
+}
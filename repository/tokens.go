@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"blogpost/middleware"
+	"blogpost/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrSessionRevoked      = errors.New("session revoked")
+)
+
+// TokenPair is returned by Login and RefreshTokens
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// issueTokens signs an access token and stores a new refresh token in the given family,
+// mfa records that the family was started with a second factor
+func (db *DbConnection) issueTokens(tx *gorm.DB, user models.User, familyID uuid.UUID, mfa bool) (*TokenPair, *models.RefreshToken, error) {
+	secret, err := randomToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	refresh := models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(secret),
+		CreatedAt: now,
+		ExpiresAt: now.Add(db.Config.JWT.RefreshTTL),
+		MFA:       mfa,
+	}
+
+	if err := tx.Create(&refresh).Error; err != nil {
+		return nil, nil, err
+	}
+
+	principal := middleware.Principal{UserID: user.ID, Email: user.Mail, Role: user.Role, SessionID: familyID, MFA: mfa}
+	access, err := middleware.AccessToken(db.Keys, principal, db.Config.JWT.AccessTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &TokenPair{AccessToken: access, RefreshToken: secret, ExpiresAt: now.Add(db.Config.JWT.AccessTTL)}, &refresh, nil
+}
+
+// startSession records a new session for the user on the client and starts its refresh token family
+func (db *DbConnection) startSession(user models.User, mfa bool, client Client) (*TokenPair, error) {
+	if user.SuspendedAt != nil {
+		db.Logger.Printf("Login of the suspended user with mailID %v refused", user.Mail)
+		return nil, middleware.ErrAccountSuspended
+	}
+
+	var tokens *TokenPair
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		session := models.Session{
+			ID:         uuid.New(),
+			UserID:     user.ID,
+			UserAgent:  client.UserAgent,
+			IP:         client.IP,
+			MFA:        mfa,
+			CreatedAt:  now,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(db.Config.JWT.RefreshTTL),
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		tokens, _, err = db.issueTokens(tx, user, session.ID, mfa)
+		return err
+	})
+	if err != nil {
+		db.Logger.Printf("Error creating the token: %v", err)
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// RefreshTokens rotates the refresh token: the presented token is revoked and replaced by a new one in the same family.
+// Presenting a token that was already rotated means it leaked, so the whole family is revoked.
+// The session moves to the client and its expiry follows the new refresh token.
+func (db *DbConnection) RefreshTokens(refreshToken string, client Client) (*TokenPair, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var pair *TokenPair
+	reused := false
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		current := models.RefreshToken{}
+		if err := tx.Where("token_hash=?", hashToken(refreshToken)).First(&current).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		if current.RevokedAt != nil {
+			reused = current.ReplacedByID != nil
+			return ErrInvalidRefreshToken
+		}
+
+		if time.Now().After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		user := models.User{}
+		if err := tx.First(&user, "id=?", current.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		if user.SuspendedAt != nil {
+			return middleware.ErrAccountSuspended
+		}
+
+		next, refresh, err := db.issueTokens(tx, user, current.FamilyID, current.MFA)
+		if err != nil {
+			return err
+		}
+
+		// only one concurrent rotation of the same token may win
+		result := tx.Model(&models.RefreshToken{}).Where("id=?", current.ID).Where("revoked_at IS NULL").
+			Updates(map[string]interface{}{"revoked_at": time.Now(), "replaced_by_id": refresh.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			reused = true
+			return ErrInvalidRefreshToken
+		}
+
+		err = tx.Model(&models.Session{}).Where("id=?", current.FamilyID).Updates(map[string]interface{}{
+			"ip":           client.IP,
+			"user_agent":   client.UserAgent,
+			"last_seen_at": time.Now(),
+			"expires_at":   refresh.ExpiresAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		pair = next
+		return nil
+	})
+
+	if reused {
+		db.Logger.Printf("Refresh token reuse detected, revoking its session")
+		if err := db.revokeFamilyOfToken(refreshToken); err != nil {
+			db.Logger.Printf("Error, %v Occured when revoking the session of a reused refresh token", err)
+		}
+	}
+
+	if err != nil {
+		db.Logger.Printf("Error refreshing the tokens: %v", err)
+		return nil, err
+	}
+
+	db.Logger.Printf("Refreshed the tokens")
+	return pair, nil
+}
+
+// Logout revokes the session the access token belongs to
+func (db *DbConnection) Logout(principal middleware.Principal) error {
+	if err := db.revokeFamily(principal.SessionID); err != nil {
+		db.Logger.Printf("Error, %v Occured when revoking the session: %v", err, principal.SessionID)
+		return err
+	}
+
+	db.Logger.Printf("User with ID %v logged out", principal.UserID)
+	return nil
+}
+
+// RevokeAllSessions revokes every session of the user, which also invalidates their access tokens
+func (db *DbConnection) RevokeAllSessions(userID uuid.UUID) error {
+	if err := revokeSessions(db.DB, userID); err != nil {
+		db.Logger.Printf("Error, %v Occured when revoking the sessions of the user with ID: %v", err, userID)
+		return err
+	}
+
+	db.Logger.Printf("Revoked all the sessions of the user with ID: %v", userID)
+	return nil
+}
+
+// ValidateSession accepts the access token only while its session is neither revoked nor expired
+// and the account is not suspended
+func (db *DbConnection) ValidateSession(principal middleware.Principal) error {
+	var suspended int64
+	if err := db.DB.Model(&models.User{}).Where("id=?", principal.UserID).Where("suspended_at IS NOT NULL").Count(&suspended).Error; err != nil {
+		return err
+	}
+
+	if suspended > 0 {
+		return middleware.ErrAccountSuspended
+	}
+
+	session := models.Session{}
+	err := db.DB.Where("id=?", principal.SessionID).Where("user_id=?", principal.UserID).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionRevoked
+		}
+		return err
+	}
+
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return ErrSessionRevoked
+	}
+
+	db.touchSession(session)
+	return nil
+}
+
+func (db *DbConnection) revokeFamily(familyID uuid.UUID) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		return revokeSession(tx, familyID)
+	})
+}
+
+func (db *DbConnection) revokeFamilyOfToken(refreshToken string) error {
+	token := models.RefreshToken{}
+	if err := db.DB.Where("token_hash=?", hashToken(refreshToken)).First(&token).Error; err != nil {
+		return err
+	}
+
+	return db.revokeFamily(token.FamilyID)
+}
+
+// randomToken returns 32 random bytes encoded for use in URLs and cookies
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken is how opaque tokens are stored, they have enough entropy that a fast hash is sufficient
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,8 +1,11 @@
 package router
 
 import (
+	"blogpost/config"
 	"blogpost/handler"
+	"blogpost/mailer"
 	"blogpost/middleware"
+	"blogpost/rbac"
 	"blogpost/repository"
 	"fmt"
 	"log"
@@ -10,8 +13,8 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func Routing(db *repository.DbConnection) {
-	h := handler.Newhandler(db)
+func Routing(db *repository.DbConnection, mail mailer.Mailer, cfg *config.Config) {
+	h := handler.Newhandler(db, mail)
 
 	app := fiber.New()
 	logger := log.New(log.Writer(), "Blog-Post ", log.LstdFlags)
@@ -22,31 +25,86 @@ func Routing(db *repository.DbConnection) {
 		return c.Next()
 	})
 
+	// lets other services verify the access tokens
+	app.Get("/.well-known/jwks.json", h.JWKS)
+
 	routes := app.Group("/blogpost/v1/")
 	routes.Post("/signup", h.AddUser)
 	routes.Post("/login/*", h.Login)
-	routes.Get("/get-role-id", h.GetRoleID)
+	routes.Post("/token/refresh", h.RefreshTokens)
+
+	// login through the OpenID Connect provider, ending like the password login
+	routes.Get("/oidc/login", h.OIDCLogin)
+	routes.Get("/oidc/callback", h.OIDCCallback)
+
+	routes.Post("/password/forgot", h.ForgotPassword)
+	routes.Get("/verify-email", h.VerifyEmail)
+	routes.Post("/verify-email/resend", h.ResendVerification)
+	routes.Post("/password/reset", h.ResetPassword)
+	routes.Get("/email-change/confirm", h.ConfirmEmailChange)
 	routes.Get("/search-all-posts", h.SearchAllPost)
 	routes.Get("/get-all-category", h.GetAllCategory)
 	routes.Get("/get-post-by-category", h.GetPostBasedOnCategory)
 	routes.Get("/get-post-statistics", h.GetPostStatistics)
 	routes.Get("/get-comment-based-on-post", h.GetCommentsBasedOnPostID)
 
-	adminroutes := app.Group("/blogpost/v1/admin")
-	adminroutes.Post("/add-post", middleware.AdminAuthorize([]byte("secret"), h.AddPost))
-	adminroutes.Get("/get-posts-by-role-id", middleware.AdminAuthorize([]byte("secret"), h.GetPostBasedOnRoleID))
-	adminroutes.Put("/update-post-by-id", middleware.AdminAuthorize([]byte("secret"), h.UpdatePostByID))
-	adminroutes.Delete("/delete-post-by-id", middleware.AdminAuthorize([]byte("secret"), h.DeletePostByID))
+	// every admin and member route requires a valid token, sent as a Bearer header or the access_token cookie
+	// the session behind the token is checked on every request so logging out takes effect immediately
+	authenticate := middleware.Authenticate(db.Keys, db, db)
+
+	routes.Post("/logout", authenticate, h.Logout)
+	routes.Post("/logout-all", authenticate, h.LogoutAll)
+	routes.Get("/sessions", authenticate, h.ListSessions)
+	routes.Delete("/sessions/:id", authenticate, h.RevokeSession)
+
+	// two-factor authentication, the enrollment stays reachable for users whose role requires it
+	routes.Post("/2fa/login", h.LoginMFA)
+	routes.Post("/2fa/enroll", authenticate, h.EnrollTOTP)
+	routes.Post("/2fa/confirm", authenticate, h.ConfirmTOTP)
+	routes.Post("/2fa/disable", authenticate, h.DisableTOTP)
+	requireMFA := middleware.RequireMFA(db)
+
+	// the caller's own account
+	me := app.Group("/blogpost/v1/me", authenticate, requireMFA)
+	me.Get("/", h.Me)
+	me.Put("/password", h.ChangePassword)
+	me.Put("/email", h.ChangeEmail)
+	me.Delete("/", h.DeleteAccount)
+
+	// API keys for automation, they act as their owner limited to their scopes
+	apiKeyRoutes := app.Group("/blogpost/v1/api-keys", authenticate, requireMFA)
+	apiKeyRoutes.Post("/", h.CreateAPIKey)
+	apiKeyRoutes.Get("/", h.ListAPIKeys)
+	apiKeyRoutes.Delete("/:id", h.RevokeAPIKey)
+
+	adminroutes := app.Group("/blogpost/v1/admin", authenticate, requireMFA)
+	adminroutes.Post("/add-post", middleware.RequirePermission(db, rbac.PostCreate), h.AddPost)
+	adminroutes.Get("/get-posts-by-role-id", middleware.RequirePermission(db, rbac.PostCreate), h.GetPostBasedOnRoleID)
+	adminroutes.Put("/update-post-by-id", middleware.RequirePermission(db, rbac.PostEditOwn), h.UpdatePostByID)
+	adminroutes.Put("/posts/:id/status", middleware.RequirePermission(db, rbac.PostEditOwn), h.ChangePostStatus)
+	adminroutes.Get("/posts/:id/revisions", middleware.RequirePermission(db, rbac.PostEditOwn), h.ListRevisions)
+	adminroutes.Get("/posts/:id/revisions/diff", middleware.RequirePermission(db, rbac.PostEditOwn), h.DiffRevisions)
+	adminroutes.Post("/posts/:id/revisions/:number/restore", middleware.RequirePermission(db, rbac.PostEditOwn), h.RestoreRevision)
+	adminroutes.Delete("/delete-post-by-id", middleware.RequirePermission(db, rbac.PostDeleteOwn), h.DeletePostByID)
+	adminroutes.Post("/unlock-user", middleware.RequirePermission(db, rbac.UserManage), h.UnlockUser)
+	adminroutes.Put("/roles/require-2fa", middleware.RequirePermission(db, rbac.RoleAssign), h.SetRoleRequires2FA)
+	adminroutes.Get("/users", middleware.RequirePermission(db, rbac.UserManage), h.ListUsers)
+	adminroutes.Put("/users/:id/role", middleware.RequirePermission(db, rbac.RoleAssign), h.ChangeUserRole)
+	adminroutes.Post("/users/:id/suspend", middleware.RequirePermission(db, rbac.UserManage), h.SuspendUser)
+	adminroutes.Post("/users/:id/unsuspend", middleware.RequirePermission(db, rbac.UserManage), h.UnsuspendUser)
+	adminroutes.Post("/users/:id/logout", middleware.RequirePermission(db, rbac.UserManage), h.ForceLogout)
+	adminroutes.Post("/users/:id/reset-credentials", middleware.RequirePermission(db, rbac.UserManage), h.ResetUserCredentials)
+	adminroutes.Get("/audit-log", middleware.RequirePermission(db, rbac.UserManage), h.ListAuditLog)
 
-	memberRoutes := app.Group("/blogpost/v1/member")
-	memberRoutes.Get("/get-post-by-id", middleware.MemberAuthorize([]byte("secret"), h.GetPostBasedOnPostID))
-	memberRoutes.Post("/add-comment", middleware.MemberAuthorize([]byte("secret"), h.AddComments))
-	memberRoutes.Put("/update-comment", middleware.MemberAuthorize([]byte("secret"), h.UpdateCommentByID))
-	memberRoutes.Delete("/delete-comment", middleware.MemberAuthorize([]byte("secret"), h.DeleteCommentByID))
-	memberRoutes.Get("/get-comment-based-on-user", middleware.MemberAuthorize([]byte("secret"), h.GetCommentsBasedOnUser))
+	memberRoutes := app.Group("/blogpost/v1/member", authenticate, requireMFA)
+	memberRoutes.Get("/get-post-by-id", middleware.RequirePermission(db, rbac.PostRead), h.GetPostBasedOnPostID)
+	memberRoutes.Post("/add-comment", middleware.RequirePermission(db, rbac.CommentCreate), h.AddComments)
+	memberRoutes.Put("/update-comment", middleware.RequirePermission(db, rbac.CommentEditOwn), h.UpdateCommentByID)
+	memberRoutes.Delete("/delete-comment", middleware.RequirePermission(db, rbac.CommentDeleteOwn), h.DeleteCommentByID)
+	memberRoutes.Get("/get-comment-based-on-user", middleware.RequirePermission(db, rbac.CommentCreate), h.GetCommentsBasedOnUser)
 
 	logger.Println("Server Started")
-	if err := app.Listen(":8000"); err != nil {
+	if err := app.Listen(cfg.Addr()); err != nil {
 		logger.Println("Server Ended")
 		fmt.Println("Ended:", err)
 		return
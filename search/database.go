@@ -0,0 +1,98 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Database searches with the full-text search of MySQL or PostgreSQL, over the FULLTEXT index or the
+// search_vector column the migrations add to the posts. The database keeps them up to date itself.
+type Database struct {
+	DB *gorm.DB
+}
+
+// NewDatabase returns the database backend, only MySQL and PostgreSQL have one
+func NewDatabase(db *gorm.DB) (*Database, error) {
+	switch db.Dialector.Name() {
+	case "mysql", "postgres":
+		return &Database{DB: db}, nil
+	}
+
+	return nil, fmt.Errorf("the %v database has no full-text search, use the memory search backend", db.Dialector.Name())
+}
+
+func (d *Database) Index(Document) error {
+	return nil
+}
+
+func (d *Database) Remove(uuid.UUID) error {
+	return nil
+}
+
+func (d *Database) Search(query Query, limit int) ([]Hit, error) {
+	rows := []struct {
+		ID    uuid.UUID
+		Score float64
+	}{}
+
+	var err error
+	if d.DB.Dialector.Name() == "mysql" {
+		match := "MATCH(title, description) AGAINST (? IN BOOLEAN MODE)"
+		boolean := mysqlQuery(query)
+		err = d.DB.Table("posts").Select("id, "+match+" AS score", boolean).Where(match, boolean).
+			Order("score desc").Order("id").Limit(limit).Scan(&rows).Error
+	} else {
+		tsquery := postgresQuery(query)
+		err = d.DB.Table("posts").Select("id, ts_rank(search_vector, to_tsquery('simple', ?)) AS score", tsquery).
+			Where("search_vector @@ to_tsquery('simple', ?)", tsquery).
+			Order("score desc").Order("id").Limit(limit).Scan(&rows).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, 0, len(rows))
+	for _, row := range rows {
+		hits = append(hits, Hit{ID: row.ID, Score: row.Score})
+	}
+
+	return hits, nil
+}
+
+// mysqlQuery writes the query in the boolean mode syntax, every term required.
+// The words are only letters and digits so nothing needs escaping.
+func mysqlQuery(query Query) string {
+	terms := make([]string, 0, len(query.Terms))
+	for _, term := range query.Terms {
+		switch {
+		case term.IsPhrase():
+			terms = append(terms, `+"`+strings.Join(term.Words, " ")+`"`)
+		case term.Prefix:
+			terms = append(terms, "+"+term.Words[0]+"*")
+		default:
+			terms = append(terms, "+"+term.Words[0])
+		}
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// postgresQuery writes the query in the tsquery syntax
+func postgresQuery(query Query) string {
+	terms := make([]string, 0, len(query.Terms))
+	for _, term := range query.Terms {
+		switch {
+		case term.IsPhrase():
+			terms = append(terms, "("+strings.Join(term.Words, " <-> ")+")")
+		case term.Prefix:
+			terms = append(terms, term.Words[0]+":*")
+		default:
+			terms = append(terms, term.Words[0])
+		}
+	}
+
+	return strings.Join(terms, " & ")
+}
@@ -0,0 +1,221 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// the ranking is BM25 with the title counting more than the description
+const (
+	bm25K1      = 1.2
+	bm25B       = 0.75
+	titleWeight = 3
+)
+
+// positions of a word in the two fields of a document
+type positions struct {
+	Title       []int
+	Description []int
+}
+
+type indexedDoc struct {
+	Words  []string
+	Length int
+}
+
+// Index is an inverted index kept in memory, for SQLite and development where the database has no full-text search.
+// It is filled when the service starts and kept up to date as posts change.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[uuid.UUID]*positions
+	docs     map[uuid.UUID]indexedDoc
+	length   int
+}
+
+func NewIndex() *Index {
+	return &Index{postings: map[string]map[uuid.UUID]*positions{}, docs: map[uuid.UUID]indexedDoc{}}
+}
+
+func (ix *Index) Index(doc Document) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(doc.ID)
+
+	words := map[string]*positions{}
+	title, description := Tokenize(doc.Title), Tokenize(doc.Description)
+	for i, word := range title {
+		if words[word] == nil {
+			words[word] = &positions{}
+		}
+		words[word].Title = append(words[word].Title, i)
+	}
+
+	for i, word := range description {
+		if words[word] == nil {
+			words[word] = &positions{}
+		}
+		words[word].Description = append(words[word].Description, i)
+	}
+
+	indexed := indexedDoc{Length: titleWeight*len(title) + len(description)}
+	for word, found := range words {
+		if ix.postings[word] == nil {
+			ix.postings[word] = map[uuid.UUID]*positions{}
+		}
+		ix.postings[word][doc.ID] = found
+		indexed.Words = append(indexed.Words, word)
+	}
+
+	ix.docs[doc.ID] = indexed
+	ix.length += indexed.Length
+	return nil
+}
+
+func (ix *Index) Remove(id uuid.UUID) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+	return nil
+}
+
+func (ix *Index) remove(id uuid.UUID) {
+	indexed, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, word := range indexed.Words {
+		delete(ix.postings[word], id)
+		if len(ix.postings[word]) == 0 {
+			delete(ix.postings, word)
+		}
+	}
+
+	delete(ix.docs, id)
+	ix.length -= indexed.Length
+}
+
+func (ix *Index) Search(query Query, limit int) ([]Hit, error) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	if len(ix.docs) == 0 {
+		return []Hit{}, nil
+	}
+
+	averageLength := float64(ix.length) / float64(len(ix.docs))
+	scores := map[uuid.UUID]float64{}
+
+	for i, term := range query.Terms {
+		frequencies := ix.frequencies(term)
+
+		// every term must match
+		if i > 0 {
+			for id := range scores {
+				if _, ok := frequencies[id]; !ok {
+					delete(scores, id)
+				}
+			}
+		}
+
+		df := float64(len(frequencies))
+		idf := math.Log(1 + (float64(len(ix.docs))-df+0.5)/(df+0.5))
+		for id, tf := range frequencies {
+			if _, ok := scores[id]; !ok && i > 0 {
+				continue
+			}
+
+			norm := 1 - bm25B + bm25B*float64(ix.docs[id].Length)/averageLength
+			scores[id] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+
+		if len(scores) == 0 {
+			break
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID.String() < hits[j].ID.String()
+	})
+
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits, nil
+}
+
+// frequencies returns how often the term occurs in each document containing it, title occurrences weighted
+func (ix *Index) frequencies(term Term) map[uuid.UUID]float64 {
+	frequencies := map[uuid.UUID]float64{}
+
+	if term.IsPhrase() {
+		for id, first := range ix.postings[term.Words[0]] {
+			title := ix.phraseCount(id, term.Words, first.Title, func(p *positions) []int { return p.Title })
+			description := ix.phraseCount(id, term.Words, first.Description, func(p *positions) []int { return p.Description })
+			if title+description > 0 {
+				frequencies[id] = float64(titleWeight*title + description)
+			}
+		}
+		return frequencies
+	}
+
+	words := []string{term.Words[0]}
+	if term.Prefix {
+		words = words[:0]
+		for word := range ix.postings {
+			if strings.HasPrefix(word, term.Words[0]) {
+				words = append(words, word)
+			}
+		}
+	}
+
+	for _, word := range words {
+		for id, found := range ix.postings[word] {
+			frequencies[id] += float64(titleWeight*len(found.Title) + len(found.Description))
+		}
+	}
+
+	return frequencies
+}
+
+// phraseCount counts the starts of the phrase in one field of the document
+func (ix *Index) phraseCount(id uuid.UUID, words []string, starts []int, field func(*positions) []int) int {
+	count := 0
+	for _, start := range starts {
+		found := true
+		for offset, word := range words[1:] {
+			next, ok := ix.postings[word][id]
+			if !ok || !contains(field(next), start+offset+1) {
+				found = false
+				break
+			}
+		}
+
+		if found {
+			count++
+		}
+	}
+
+	return count
+}
+
+// contains looks the position up in the sorted positions
+func contains(sorted []int, position int) bool {
+	i := sort.SearchInts(sorted, position)
+	return i < len(sorted) && sorted[i] == position
+}
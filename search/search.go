@@ -0,0 +1,130 @@
+// Package search finds posts by the words of their title and description.
+// A Backend ranks the matching posts, either the database's own full-text search or the in-process Index.
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// MaxHits is the most hits a search returns, the rest are not worth paging to
+const MaxHits = 1000
+
+var ErrEmptyQuery = errors.New("the search query has no words")
+
+// Document is what is indexed of a post
+type Document struct {
+	ID          uuid.UUID
+	Title       string
+	Description string
+}
+
+// Hit is a matching post, a higher score is more relevant
+type Hit struct {
+	ID    uuid.UUID
+	Score float64
+}
+
+// Backend indexes the posts and finds those matching every term of a query, best first
+type Backend interface {
+	// Index adds the document or replaces it
+	Index(doc Document) error
+	Remove(id uuid.UUID) error
+	Search(query Query, limit int) ([]Hit, error)
+}
+
+// Term is a word, a "quoted phrase" whose words must follow each other, or a prefix written as word*
+type Term struct {
+	Words  []string
+	Prefix bool
+}
+
+// IsPhrase reports whether the term is more than one word
+func (t Term) IsPhrase() bool {
+	return len(t.Words) > 1
+}
+
+// Query is a parsed search, a post matches when it matches every term
+type Query struct {
+	Terms []Term
+}
+
+// Parse splits the search into its terms. Words are lower cased and everything but letters and digits separates them,
+// an unclosed quote runs to the end.
+func Parse(q string) (Query, error) {
+	query := Query{}
+
+	for i, part := range strings.Split(q, `"`) {
+		// the odd parts were between quotes
+		if i%2 == 1 {
+			if words := Tokenize(part); len(words) > 0 {
+				query.Terms = append(query.Terms, Term{Words: words})
+			}
+			continue
+		}
+
+		for _, field := range strings.Fields(part) {
+			prefix := strings.HasSuffix(field, "*")
+			words := Tokenize(field)
+			for j, word := range words {
+				// only the last word of a field like e-mail* is a prefix
+				query.Terms = append(query.Terms, Term{Words: []string{word}, Prefix: prefix && j == len(words)-1})
+			}
+		}
+	}
+
+	if len(query.Terms) == 0 {
+		return query, ErrEmptyQuery
+	}
+
+	return query, nil
+}
+
+// Tokenize returns the lower cased words of the text
+func Tokenize(text string) []string {
+	words := []string{}
+	for _, token := range tokens(text) {
+		words = append(words, token.Word)
+	}
+
+	return words
+}
+
+// token is a word and where it is in the text
+type token struct {
+	Word       string
+	Start, End int
+}
+
+func tokens(text string) []token {
+	found := []token{}
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			found = append(found, token{Word: strings.ToLower(text[start:i]), Start: start, End: i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		found = append(found, token{Word: strings.ToLower(text[start:]), Start: start, End: len(text)})
+	}
+
+	return found
+}
+
+// matches reports whether the word is the term's only word, or starts with it for a prefix
+func (t Term) matches(word string) bool {
+	if t.Prefix {
+		return strings.HasPrefix(word, t.Words[0])
+	}
+
+	return word == t.Words[0]
+}
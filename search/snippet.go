@@ -0,0 +1,126 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+const (
+	MarkStart = "<mark>"
+	MarkEnd   = "</mark>"
+	ellipsis  = "…"
+	// snippetLead is how many words a snippet shows before the first match
+	snippetLead = 5
+)
+
+// Highlight escapes the text for HTML and wraps the words matching the query in <mark>
+func Highlight(text string, query Query) string {
+	found := tokens(text)
+	return highlight(text, found, matched(found, query), 0, len(text))
+}
+
+// Snippet returns about the given number of words of the text around the first match, escaped and highlighted like
+// Highlight. Without a match it is the start of the text.
+func Snippet(text string, query Query, words int) string {
+	found := tokens(text)
+	if len(found) == 0 {
+		return ""
+	}
+
+	marks := matched(found, query)
+
+	first := 0
+	for i := range found {
+		if marks[i] {
+			first = i
+			break
+		}
+	}
+
+	start := first - snippetLead
+	if start < 0 {
+		start = 0
+	}
+
+	end := start + words
+	if end > len(found) {
+		end = len(found)
+		// fill the snippet from before the match when it is near the end
+		if start = end - words; start < 0 {
+			start = 0
+		}
+	}
+
+	from, to := found[start].Start, found[end-1].End
+	if start == 0 {
+		from = 0
+	}
+
+	if end == len(found) {
+		to = len(text)
+	}
+
+	snippet := highlight(text, found[start:end], marks[start:end], from, to)
+	if start > 0 {
+		snippet = ellipsis + snippet
+	}
+
+	if end < len(found) {
+		snippet += ellipsis
+	}
+
+	return snippet
+}
+
+// matched flags the tokens that match a word or prefix of the query or belong to one of its phrases
+func matched(found []token, query Query) []bool {
+	marks := make([]bool, len(found))
+	for _, term := range query.Terms {
+		if !term.IsPhrase() {
+			for i, t := range found {
+				if term.matches(t.Word) {
+					marks[i] = true
+				}
+			}
+			continue
+		}
+
+		for i := 0; i+len(term.Words) <= len(found); i++ {
+			phrase := true
+			for j, word := range term.Words {
+				if found[i+j].Word != word {
+					phrase = false
+					break
+				}
+			}
+
+			if phrase {
+				for j := range term.Words {
+					marks[i+j] = true
+				}
+			}
+		}
+	}
+
+	return marks
+}
+
+// highlight escapes text[from:to] and marks the flagged tokens, which must lie within it
+func highlight(text string, found []token, marks []bool, from, to int) string {
+	var b strings.Builder
+	last := from
+	for i, t := range found {
+		if !marks[i] {
+			continue
+		}
+
+		b.WriteString(html.EscapeString(text[last:t.Start]))
+		b.WriteString(MarkStart)
+		b.WriteString(html.EscapeString(text[t.Start:t.End]))
+		b.WriteString(MarkEnd)
+		last = t.End
+	}
+
+	b.WriteString(html.EscapeString(text[last:to]))
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package seeds
+
+import (
+	"blogpost/config"
+	"blogpost/models"
+	"blogpost/passwords"
+	"blogpost/rbac"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func seedAdmin(tx *gorm.DB, cfg *config.Config, logger *log.Logger) error {
+	if cfg.Admin.Email == "" {
+		logger.Printf("No bootstrap admin configured, skipping")
+		return nil
+	}
+
+	if cfg.Admin.Password == "" {
+		return errors.New("the bootstrap admin needs a password, set admin.password or BLOGPOST_ADMIN_PASSWORD")
+	}
+
+	policy, err := passwords.LoadPolicy(cfg.Password)
+	if err != nil {
+		return err
+	}
+
+	if err := policy.Check(cfg.Admin.Password, cfg.Admin.Email); err != nil {
+		return fmt.Errorf("the bootstrap admin password: %v", err)
+	}
+
+	_, err = ensureUser(tx, cfg, logger, cfg.Admin.Email, cfg.Admin.Password, rbac.RoleAdmin)
+	return err
+}
+
+// ensureUser returns the user with the given mail, creating it with the role when it does not exist yet.
+// An existing user is left untouched.
+func ensureUser(tx *gorm.DB, cfg *config.Config, logger *log.Logger, mail, password, role string) (*models.User, error) {
+	user := models.User{}
+
+	err := tx.Where("mail=?", mail).First(&user).Error
+	if err == nil {
+		logger.Printf("User %s already exists, leaving it unchanged", mail)
+		return &user, nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	hash, err := passwords.NewHasher(cfg.Password).Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	// seeded accounts are trusted and need no verification mail
+	verifiedAt := time.Now()
+	user = models.User{ID: uuid.New(), Role: role, Mail: mail, Password: hash, EmailVerifiedAt: &verifiedAt}
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, err
+	}
+
+	assigned := models.Role{}
+	if err := tx.Where("name=?", role).First(&assigned).Error; err != nil {
+		return nil, fmt.Errorf("unknown role %q: %v", role, err)
+	}
+
+	if err := tx.Create(&models.UserRole{UserID: user.ID, RoleID: assigned.ID}).Error; err != nil {
+		return nil, err
+	}
+
+	logger.Printf("Added %s user %s with ID %v", role, mail, user.ID)
+	return &user, nil
+}
@@ -0,0 +1,35 @@
+package seeds
+
+import (
+	"blogpost/config"
+	"blogpost/models"
+	"log"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var defaultCategories = []string{
+	"Technology",
+	"Travel",
+	"Food",
+	"Health",
+	"Lifestyle",
+	"Business",
+	"Education",
+}
+
+func seedCategories(tx *gorm.DB, cfg *config.Config, logger *log.Logger) error {
+	for _, name := range defaultCategories {
+		result := tx.Where("name=?", name).Attrs(models.Category{ID: uuid.New()}).FirstOrCreate(&models.Category{Name: name})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected > 0 {
+			logger.Printf("Added category %s", name)
+		}
+	}
+
+	return nil
+}
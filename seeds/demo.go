@@ -0,0 +1,186 @@
+package seeds
+
+import (
+	"blogpost/config"
+	"blogpost/models"
+	"blogpost/passwords"
+	"blogpost/rbac"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	demoAdminMail    = "demo-admin@blogpost.local"
+	demoMemberMail   = "demo-member@blogpost.local"
+	demoPostInterval = 24 * time.Hour
+	// demoPasswordLength is the length of the generated demo passwords, unless the policy asks for another
+	demoPasswordLength = 20
+)
+
+var demoPosts = []struct {
+	Title       string
+	Category    string
+	Description string
+	Comments    []string
+}{
+	{
+		Title:       "Getting started with the blog",
+		Category:    "Technology",
+		Description: "A short tour of how posts, categories and comments fit together.",
+		Comments:    []string{"Thanks, this was helpful!", "Looking forward to the next post."},
+	},
+	{
+		Title:       "A weekend in the mountains",
+		Category:    "Travel",
+		Description: "Notes from a quiet weekend away from the screen.",
+		Comments:    []string{"The view must have been great."},
+	},
+	{
+		Title:       "Five minute breakfast ideas",
+		Category:    "Food",
+		Description: "Quick breakfasts for busy mornings.",
+	},
+}
+
+func seedDemo(tx *gorm.DB, cfg *config.Config, logger *log.Logger) error {
+	author, err := demoAuthor(tx, cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	member, err := demoUser(tx, cfg, logger, demoMemberMail, rbac.RoleMember)
+	if err != nil {
+		return err
+	}
+
+	for i, demo := range demoPosts {
+		post := models.Post{}
+
+		err := tx.Where("title=?", demo.Title).First(&post).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			post = models.Post{
+				ID:          uuid.New(),
+				RoleID:      author.ID,
+				Category:    demo.Category,
+				Title:       demo.Title,
+				Description: demo.Description,
+				PostDate:    time.Now().Add(-time.Duration(len(demoPosts)-i) * demoPostInterval),
+				Status:      models.PostPublished,
+			}
+			if err := tx.Create(&post).Error; err != nil {
+				return err
+			}
+
+			revision := models.PostRevision{
+				ID:          uuid.New(),
+				PostID:      post.ID,
+				Number:      1,
+				AuthorID:    author.ID,
+				Category:    post.Category,
+				Title:       post.Title,
+				Description: post.Description,
+				CreatedAt:   post.PostDate,
+			}
+			if err := tx.Create(&revision).Error; err != nil {
+				return err
+			}
+			logger.Printf("Added demo post %q", demo.Title)
+		} else if err != nil {
+			return err
+		}
+
+		for _, feedback := range demo.Comments {
+			comment := models.Comments{}
+			result := tx.Where("post_id=?", post.ID).Where("role_id=?", member.ID).Where("feedback=?", feedback).
+				Attrs(models.Comments{ID: uuid.New()}).
+				FirstOrCreate(&comment, models.Comments{PostID: post.ID, RoleID: member.ID, Feedback: feedback})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		var commentCount int64
+		if err := tx.Model(&models.Comments{}).Where("post_id=?", post.ID).Count(&commentCount).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Post{}).Where("id=?", post.ID).Update("comment_count", commentCount).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// demoAuthor posts the demo content as the bootstrap admin when there is one, otherwise as a demo admin
+func demoAuthor(tx *gorm.DB, cfg *config.Config, logger *log.Logger) (*models.User, error) {
+	if cfg.Admin.Email != "" {
+		admin := models.User{}
+		err := tx.Where("mail=?", cfg.Admin.Email).First(&admin).Error
+		if err == nil {
+			return &admin, nil
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	return demoUser(tx, cfg, logger, demoAdminMail, rbac.RoleAdmin)
+}
+
+// demoUser creates a demo account with a random password and logs it, it can not be looked up later.
+// An existing account keeps its password.
+func demoUser(tx *gorm.DB, cfg *config.Config, logger *log.Logger, mail, role string) (*models.User, error) {
+	existing := models.User{}
+	result := tx.Where("mail=?", mail).Limit(1).Find(&existing)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		logger.Printf("User %s already exists, leaving it unchanged", mail)
+		return &existing, nil
+	}
+
+	password, err := demoPassword(cfg.Password, mail)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := ensureUser(tx, cfg, logger, mail, password, role)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Printf("Demo user %s logs in with the password %s", mail, password)
+	return user, nil
+}
+
+// demoPassword generates a password the configured policy accepts
+func demoPassword(cfg config.PasswordConfig, mail string) (string, error) {
+	policy, err := passwords.LoadPolicy(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	length := min(max(demoPasswordLength, policy.MinLength), policy.MaxLength)
+	buf := make([]byte, length)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	password := base64.RawURLEncoding.EncodeToString(buf)[:length]
+	if err := policy.Check(password, mail); err != nil {
+		return "", fmt.Errorf("the generated demo password: %v", err)
+	}
+
+	return password, nil
+}
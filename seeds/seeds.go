@@ -0,0 +1,84 @@
+package seeds
+
+import (
+	"blogpost/config"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Seed is a named set of rows that can be inserted any number of times without creating duplicates
+type Seed struct {
+	Name        string
+	Description string
+	// Default seeds run when the seed command is given no names
+	Default bool
+	Run     func(tx *gorm.DB, cfg *config.Config, logger *log.Logger) error
+}
+
+var sets = []Seed{
+	{Name: "categories", Description: "default post categories", Default: true, Run: seedCategories},
+	{Name: "admin", Description: "bootstrap admin account from the admin config", Default: true, Run: seedAdmin},
+	{Name: "demo", Description: "demo member, posts and comments for development", Run: seedDemo},
+}
+
+// Sets returns every known seed set
+func Sets() []Seed {
+	return sets
+}
+
+// Run executes the named seed sets, or the default ones when names is empty, each within its own transaction
+func Run(db *gorm.DB, cfg *config.Config, logger *log.Logger, names []string) error {
+	selected := make([]Seed, 0, len(sets))
+
+	if len(names) == 0 {
+		for _, seed := range sets {
+			if seed.Default {
+				selected = append(selected, seed)
+			}
+		}
+	}
+
+	for _, name := range names {
+		seed, ok := find(name)
+		if !ok {
+			return fmt.Errorf("unknown seed set %q, available: %s", name, available())
+		}
+		selected = append(selected, seed)
+	}
+
+	for _, seed := range selected {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return seed.Run(tx, cfg, logger)
+		})
+		if err != nil {
+			logger.Printf("Error seeding %s: %v", seed.Name, err)
+			return fmt.Errorf("error seeding %s: %v", seed.Name, err)
+		}
+
+		logger.Printf("Seeded %s", seed.Name)
+	}
+
+	return nil
+}
+
+func find(name string) (Seed, bool) {
+	for _, seed := range sets {
+		if seed.Name == name {
+			return seed, true
+		}
+	}
+
+	return Seed{}, false
+}
+
+func available() string {
+	names := make([]string, 0, len(sets))
+	for _, seed := range sets {
+		names = append(names, seed.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,117 @@
+package migrators
+
+import (
+	"blogpost/models"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	// lockID is the primary key of the single row in the migration lock table
+	lockID = 1
+	// lockTTL is how long a lock survives without being refreshed, so a crashed instance does not block the others forever
+	lockTTL = 30 * time.Second
+	// lockPollInterval is how often a waiting instance retries to take the lock
+	lockPollInterval = time.Second
+)
+
+// withLock runs fn while holding the migration lock, waiting up to LockTimeout for another instance to release it
+func (m *Migrator) withLock(fn func() error) error {
+	if err := Migrations(m.DB); err != nil {
+		// another instance may have created the tables concurrently
+		if err := Migrations(m.DB); err != nil {
+			return fmt.Errorf("error creating the migration tables: %v", err)
+		}
+	}
+
+	owner := lockOwner()
+	if err := m.acquireLock(owner); err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go m.refreshLock(owner, stop, done)
+
+	defer func() {
+		close(stop)
+		<-done
+		if err := m.DB.Where("id=?", lockID).Where("owner=?", owner).Delete(&models.MigrationLock{}).Error; err != nil {
+			m.Logger.Printf("Error releasing the migration lock: %v", err)
+		}
+	}()
+
+	return fn()
+}
+
+func (m *Migrator) acquireLock(owner string) error {
+	deadline := time.Now().Add(m.LockTimeout)
+	waiting := false
+
+	for {
+		now := time.Now()
+
+		// take over a lock left behind by an instance that stopped refreshing it
+		if err := m.DB.Where("id=?", lockID).Where("expires_at<?", now).Delete(&models.MigrationLock{}).Error; err != nil {
+			return fmt.Errorf("error clearing an expired migration lock: %v", err)
+		}
+
+		// a duplicate key is the expected outcome while another instance holds the lock, keep it out of the query log
+		quiet := m.DB.Session(&gorm.Session{Logger: m.DB.Logger.LogMode(logger.Silent)})
+		err := quiet.Create(&models.MigrationLock{ID: lockID, Owner: owner, LockedAt: now, ExpiresAt: now.Add(lockTTL)}).Error
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("error acquiring the migration lock: %v", err)
+		}
+
+		if !waiting {
+			holder := models.MigrationLock{}
+			m.DB.Where("id=?", lockID).First(&holder)
+			m.Logger.Printf("Waiting for the migration lock held by %s since %s", holder.Owner, holder.LockedAt.Format(time.RFC3339))
+			waiting = true
+		}
+
+		if now.After(deadline) {
+			return fmt.Errorf("timed out after %v waiting for the migration lock held by another instance", m.LockTimeout)
+		}
+
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// refreshLock extends the lock expiry while a long migration is running
+func (m *Migrator) refreshLock(owner string, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(lockTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := m.DB.Model(&models.MigrationLock{}).Where("id=?", lockID).Where("owner=?", owner).Update("expires_at", time.Now().Add(lockTTL)).Error; err != nil {
+				m.Logger.Printf("Error refreshing the migration lock: %v", err)
+			}
+		}
+	}
+}
+
+func lockOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), uuid.NewString()[:8])
+}
@@ -1,19 +1,77 @@
 package migrators
 
 import (
-	"blogpost/models"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-type LookUpDb struct {
-	DB *gorm.DB
+// the tables as they were at this version, spelled out so later changes to the models do not affect it
+type lookup1User struct {
+	ID       uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+	Role     string    `gorm:"column:role"`
+	Mail     string    `gorm:"unique;size:190;column:mail"`
+	Password string    `gorm:"column:password"`
 }
 
-func NewLookUpDB(db *gorm.DB) *LookUpDb {
-	return &LookUpDb{DB: db}
+func (lookup1User) TableName() string {
+	return "users"
 }
 
-func (u *LookUpDb) Lookup1() {
-	u.DB.AutoMigrate(&models.User{}, &models.Post{}, &models.Comments{}, models.Views{})
+type lookup1Post struct {
+	ID           uuid.UUID   `gorm:"type:varchar(36);primaryKey;column:id"`
+	RoleID       uuid.UUID   `gorm:"type:varchar(36);column:role_id"`
+	Category     string      `gorm:"column:category"`
+	Title        string      `gorm:"unique;size:190;column:title"`
+	Description  string      `gorm:"column:description"`
+	PostDate     time.Time   `gorm:"column:post_date"`
+	CommentCount uint        `gorm:"column:comment_count"`
+	ViewsCount   int         `gorm:"column:views_count"`
+	UserCount    int         `gorm:"column:user_count"`
+	User         lookup1User `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (lookup1Post) TableName() string {
+	return "posts"
+}
+
+type lookup1Comments struct {
+	ID       uuid.UUID   `gorm:"type:varchar(36);primaryKey;column:id"`
+	PostID   uuid.UUID   `gorm:"type:varchar(36);column:post_id"`
+	RoleID   uuid.UUID   `gorm:"type:varchar(36);column:role_id"`
+	Feedback string      `gorm:"column:feedback"`
+	User     lookup1User `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Post     lookup1Post `gorm:"foreignKey:PostID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (lookup1Comments) TableName() string {
+	return "comments"
+}
+
+type lookup1Views struct {
+	ID       uuid.UUID   `gorm:"type:varchar(36);column:id"`
+	Views    int         `gorm:"column:views"`
+	RoleID   uuid.UUID   `gorm:"type:varchar(36);column:role_id"`
+	PostID   uuid.UUID   `gorm:"type:varchar(36);column:post_id"`
+	IsViewed bool        `gorm:"column:is_valid"`
+	Post     lookup1Post `gorm:"foreignKey:PostID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	User     lookup1User `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (lookup1Views) TableName() string {
+	return "views"
+}
+
+func init() {
+	Register(Migration{
+		Version: 1,
+		Name:    "lookup1",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&lookup1User{}, &lookup1Post{}, &lookup1Comments{}, &lookup1Views{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&lookup1Views{}, &lookup1Comments{}, &lookup1Post{}, &lookup1User{})
+		},
+	})
 }
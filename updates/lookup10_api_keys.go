@@ -0,0 +1,49 @@
+package migrators
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// the table this migration adds, spelled out so later changes to the models do not affect it
+type lookup10APIKey struct {
+	ID         uuid.UUID    `gorm:"type:varchar(36);primaryKey;column:id"`
+	UserID     uuid.UUID    `gorm:"type:varchar(36);index;column:user_id"`
+	Name       string       `gorm:"size:190;column:name"`
+	Prefix     string       `gorm:"unique;size:16;column:prefix"`
+	SecretHash string       `gorm:"size:64;column:secret_hash"`
+	Scopes     string       `gorm:"column:scopes"`
+	CreatedAt  time.Time    `gorm:"column:created_at"`
+	ExpiresAt  *time.Time   `gorm:"column:expires_at"`
+	LastUsedAt *time.Time   `gorm:"column:last_used_at"`
+	LastUsedIP string       `gorm:"size:64;column:last_used_ip"`
+	RevokedAt  *time.Time   `gorm:"column:revoked_at"`
+	User       lookup10User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (lookup10APIKey) TableName() string {
+	return "api_keys"
+}
+
+type lookup10User struct {
+	ID uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+}
+
+func (lookup10User) TableName() string {
+	return "users"
+}
+
+func init() {
+	Register(Migration{
+		Version: 10,
+		Name:    "lookup10_api_keys",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&lookup10APIKey{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&lookup10APIKey{})
+		},
+	})
+}
@@ -0,0 +1,58 @@
+package migrators
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// the tables this migration adds, spelled out so later changes to the models do not affect it
+type lookup11OIDCLogin struct {
+	ID           uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+	StateHash    string    `gorm:"unique;size:64;column:state_hash"`
+	Nonce        string    `gorm:"size:64;column:nonce"`
+	CodeVerifier string    `gorm:"size:128;column:code_verifier"`
+	CreatedAt    time.Time `gorm:"column:created_at"`
+	ExpiresAt    time.Time `gorm:"index;column:expires_at"`
+}
+
+// the name gorm gave the table of models.OIDCLogin
+func (lookup11OIDCLogin) TableName() string {
+	return "o_id_c_logins"
+}
+
+type lookup11UserIdentity struct {
+	ID          uuid.UUID    `gorm:"type:varchar(36);primaryKey;column:id"`
+	UserID      uuid.UUID    `gorm:"type:varchar(36);index;column:user_id"`
+	Issuer      string       `gorm:"size:190;uniqueIndex:idx_identity_subject;column:issuer"`
+	Subject     string       `gorm:"size:190;uniqueIndex:idx_identity_subject;column:subject"`
+	CreatedAt   time.Time    `gorm:"column:created_at"`
+	LastLoginAt *time.Time   `gorm:"column:last_login_at"`
+	User        lookup11User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (lookup11UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+type lookup11User struct {
+	ID uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+}
+
+func (lookup11User) TableName() string {
+	return "users"
+}
+
+func init() {
+	Register(Migration{
+		Version: 11,
+		Name:    "lookup11_oidc",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&lookup11OIDCLogin{}, &lookup11UserIdentity{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&lookup11UserIdentity{}, &lookup11OIDCLogin{})
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package migrators
+
+import (
+	"gorm.io/gorm"
+)
+
+// the column this migration adds, spelled out so later changes to the models do not affect it
+type lookup12User struct {
+	PendingMail string `gorm:"size:190;column:pending_mail"`
+}
+
+func (lookup12User) TableName() string {
+	return "users"
+}
+
+func init() {
+	Register(Migration{
+		Version: 12,
+		Name:    "lookup12_pending_mail",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&lookup12User{}, "PendingMail") {
+				return nil
+			}
+
+			return tx.Migrator().AddColumn(&lookup12User{}, "PendingMail")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&lookup12User{}, "PendingMail")
+		},
+	})
+}
@@ -0,0 +1,67 @@
+package migrators
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// the columns and the table this migration adds, spelled out so later changes to the models do not affect it
+type lookup13User struct {
+	SuspendedAt     *time.Time `gorm:"column:suspended_at"`
+	SuspendedReason string     `gorm:"size:255;column:suspended_reason"`
+}
+
+func (lookup13User) TableName() string {
+	return "users"
+}
+
+type lookup13AuditLog struct {
+	ID        uuid.UUID  `gorm:"type:varchar(36);primaryKey;column:id"`
+	ActorID   uuid.UUID  `gorm:"type:varchar(36);index;column:actor_id"`
+	Action    string     `gorm:"size:64;index;column:action"`
+	TargetID  *uuid.UUID `gorm:"type:varchar(36);index;column:target_id"`
+	Details   string     `gorm:"column:details"`
+	IP        string     `gorm:"size:64;column:ip"`
+	CreatedAt time.Time  `gorm:"index;column:created_at"`
+}
+
+func (lookup13AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+var lookup13Columns = []string{"SuspendedAt", "SuspendedReason"}
+
+func init() {
+	Register(Migration{
+		Version: 13,
+		Name:    "lookup13_user_admin",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range lookup13Columns {
+				if tx.Migrator().HasColumn(&lookup13User{}, field) {
+					continue
+				}
+
+				if err := tx.Migrator().AddColumn(&lookup13User{}, field); err != nil {
+					return err
+				}
+			}
+
+			return tx.AutoMigrate(&lookup13AuditLog{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&lookup13AuditLog{}); err != nil {
+				return err
+			}
+
+			for _, field := range lookup13Columns {
+				if err := tx.Migrator().DropColumn(&lookup13User{}, field); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	})
+}
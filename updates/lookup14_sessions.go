@@ -0,0 +1,105 @@
+package migrators
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// the table this migration adds and the refresh tokens it reads, spelled out so later changes to the models do not
+// affect it
+type lookup14Session struct {
+	ID         uuid.UUID    `gorm:"type:varchar(36);primaryKey;column:id"`
+	UserID     uuid.UUID    `gorm:"type:varchar(36);index;column:user_id"`
+	UserAgent  string       `gorm:"size:255;column:user_agent"`
+	IP         string       `gorm:"size:64;column:ip"`
+	MFA        bool         `gorm:"default:false;column:mfa"`
+	CreatedAt  time.Time    `gorm:"column:created_at"`
+	LastSeenAt time.Time    `gorm:"column:last_seen_at"`
+	ExpiresAt  time.Time    `gorm:"column:expires_at"`
+	RevokedAt  *time.Time   `gorm:"column:revoked_at"`
+	User       lookup14User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (lookup14Session) TableName() string {
+	return "sessions"
+}
+
+type lookup14User struct {
+	ID uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+}
+
+func (lookup14User) TableName() string {
+	return "users"
+}
+
+type lookup14RefreshToken struct {
+	UserID    uuid.UUID  `gorm:"column:user_id"`
+	FamilyID  uuid.UUID  `gorm:"column:family_id"`
+	CreatedAt time.Time  `gorm:"column:created_at"`
+	ExpiresAt time.Time  `gorm:"column:expires_at"`
+	RevokedAt *time.Time `gorm:"column:revoked_at"`
+	MFA       bool       `gorm:"column:mfa"`
+}
+
+func (lookup14RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+func init() {
+	Register(Migration{
+		Version: 14,
+		Name:    "lookup14_sessions",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&lookup14Session{}); err != nil {
+				return err
+			}
+
+			// every existing refresh token family becomes a session so nobody is logged out,
+			// the timestamps are aggregated here because sqlite returns MIN and MAX of them as text
+			tokens := []lookup14RefreshToken{}
+			if err := tx.Order("created_at").Find(&tokens).Error; err != nil {
+				return err
+			}
+
+			now := time.Now()
+			sessions := map[string]*lookup14Session{}
+			order := []string{}
+			for _, token := range tokens {
+				session, ok := sessions[token.FamilyID.String()]
+				if !ok {
+					session = &lookup14Session{
+						ID:        token.FamilyID,
+						UserID:    token.UserID,
+						CreatedAt: token.CreatedAt,
+						RevokedAt: &now,
+					}
+					sessions[token.FamilyID.String()] = session
+					order = append(order, token.FamilyID.String())
+				}
+
+				session.MFA = session.MFA || token.MFA
+				session.LastSeenAt = token.CreatedAt
+				if token.ExpiresAt.After(session.ExpiresAt) {
+					session.ExpiresAt = token.ExpiresAt
+				}
+
+				if token.RevokedAt == nil {
+					session.RevokedAt = nil
+				}
+			}
+
+			for _, id := range order {
+				if err := tx.Create(sessions[id]).Error; err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&lookup14Session{})
+		},
+	})
+}
@@ -0,0 +1,77 @@
+package migrators
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// the column and indexes this migration adds, spelled out so later changes to the models do not affect it
+type lookup15Comment struct {
+	PostID    string    `gorm:"type:varchar(36);column:post_id;index:idx_comments_post_created,priority:1"`
+	CreatedAt time.Time `gorm:"column:created_at;index:idx_comments_post_created,priority:2"`
+}
+
+func (lookup15Comment) TableName() string {
+	return "comments"
+}
+
+type lookup15Post struct {
+	PostDate time.Time `gorm:"column:post_date;index:idx_posts_post_date"`
+}
+
+func (lookup15Post) TableName() string {
+	return "posts"
+}
+
+func init() {
+	Register(Migration{
+		Version: 15,
+		Name:    "lookup15_comment_created_at",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&lookup15Comment{}, "CreatedAt") {
+				if err := tx.Migrator().AddColumn(&lookup15Comment{}, "CreatedAt"); err != nil {
+					return err
+				}
+
+				// the existing comments are dated with their post, the ID keeps their order stable
+				err := tx.Exec("UPDATE comments SET created_at = (SELECT post_date FROM posts WHERE posts.id = comments.post_id) WHERE created_at IS NULL").Error
+				if err != nil {
+					return err
+				}
+
+				if err := tx.Model(&lookup15Comment{}).Where("created_at IS NULL").Update("created_at", time.Now()).Error; err != nil {
+					return err
+				}
+			}
+
+			if !tx.Migrator().HasIndex(&lookup15Comment{}, "idx_comments_post_created") {
+				if err := tx.Migrator().CreateIndex(&lookup15Comment{}, "idx_comments_post_created"); err != nil {
+					return err
+				}
+			}
+
+			if !tx.Migrator().HasIndex(&lookup15Post{}, "idx_posts_post_date") {
+				return tx.Migrator().CreateIndex(&lookup15Post{}, "idx_posts_post_date")
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			// sqlite rebuilds a table to drop one of its columns, the indexes may be gone already
+			if tx.Migrator().HasIndex(&lookup15Post{}, "idx_posts_post_date") {
+				if err := tx.Migrator().DropIndex(&lookup15Post{}, "idx_posts_post_date"); err != nil {
+					return err
+				}
+			}
+
+			if tx.Migrator().HasIndex(&lookup15Comment{}, "idx_comments_post_created") {
+				if err := tx.Migrator().DropIndex(&lookup15Comment{}, "idx_comments_post_created"); err != nil {
+					return err
+				}
+			}
+
+			return tx.Migrator().DropColumn(&lookup15Comment{}, "CreatedAt")
+		},
+	})
+}
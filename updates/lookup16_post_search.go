@@ -0,0 +1,57 @@
+package migrators
+
+import (
+	"gorm.io/gorm"
+)
+
+// lookup16_post_search prepares the full-text search of the posts: a FULLTEXT index on MySQL, a weighted
+// search_vector column with a GIN index on PostgreSQL. SQLite searches with the in-process index instead.
+func init() {
+	Register(Migration{
+		Version: 16,
+		Name:    "lookup16_post_search",
+		Up: func(tx *gorm.DB) error {
+			switch tx.Dialector.Name() {
+			case "mysql":
+				if tx.Migrator().HasIndex("posts", "idx_posts_fulltext") {
+					return nil
+				}
+
+				return tx.Exec("CREATE FULLTEXT INDEX idx_posts_fulltext ON posts (title, description)").Error
+
+			case "postgres":
+				if !tx.Migrator().HasColumn("posts", "search_vector") {
+					err := tx.Exec("ALTER TABLE posts ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (" +
+						"setweight(to_tsvector('simple', coalesce(title, '')), 'A') || " +
+						"setweight(to_tsvector('simple', coalesce(description, '')), 'B')) STORED").Error
+					if err != nil {
+						return err
+					}
+				}
+
+				return tx.Exec("CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING GIN (search_vector)").Error
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			switch tx.Dialector.Name() {
+			case "mysql":
+				if !tx.Migrator().HasIndex("posts", "idx_posts_fulltext") {
+					return nil
+				}
+
+				return tx.Exec("DROP INDEX idx_posts_fulltext ON posts").Error
+
+			case "postgres":
+				if err := tx.Exec("DROP INDEX IF EXISTS idx_posts_search_vector").Error; err != nil {
+					return err
+				}
+
+				return tx.Exec("ALTER TABLE posts DROP COLUMN IF EXISTS search_vector").Error
+			}
+
+			return nil
+		},
+	})
+}
@@ -0,0 +1,70 @@
+package migrators
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// the columns this migration adds, spelled out so later changes to the models do not affect it
+type lookup17Post struct {
+	Status    string     `gorm:"size:16;default:published;index:idx_posts_status;column:status"`
+	PublishAt *time.Time `gorm:"column:publish_at"`
+}
+
+func (lookup17Post) TableName() string {
+	return "posts"
+}
+
+func init() {
+	Register(Migration{
+		Version: 17,
+		Name:    "lookup17_post_status",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&lookup17Post{}, "Status") {
+				if err := tx.Migrator().AddColumn(&lookup17Post{}, "Status"); err != nil {
+					return err
+				}
+
+				// the posts written so far were visible to everyone
+				if err := tx.Exec("UPDATE posts SET status = 'published' WHERE status IS NULL OR status = ''").Error; err != nil {
+					return err
+				}
+			}
+
+			if !tx.Migrator().HasColumn(&lookup17Post{}, "PublishAt") {
+				if err := tx.Migrator().AddColumn(&lookup17Post{}, "PublishAt"); err != nil {
+					return err
+				}
+			}
+
+			if !tx.Migrator().HasIndex(&lookup17Post{}, "idx_posts_status") {
+				return tx.Migrator().CreateIndex(&lookup17Post{}, "idx_posts_status")
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Migrator().HasIndex(&lookup17Post{}, "idx_posts_status") {
+				if err := tx.Migrator().DropIndex(&lookup17Post{}, "idx_posts_status"); err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Migrator().DropColumn(&lookup17Post{}, "PublishAt"); err != nil {
+				return err
+			}
+
+			if err := tx.Migrator().DropColumn(&lookup17Post{}, "Status"); err != nil {
+				return err
+			}
+
+			// sqlite rebuilds the table to drop the columns and loses the index of lookup15 on the way
+			if !tx.Migrator().HasIndex(&lookup15Post{}, "idx_posts_post_date") {
+				return tx.Migrator().CreateIndex(&lookup15Post{}, "idx_posts_post_date")
+			}
+
+			return nil
+		},
+	})
+}
@@ -0,0 +1,79 @@
+package migrators
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// the table this migration adds and the posts it reads, spelled out so later changes to the models do not affect it
+type lookup18PostRevision struct {
+	ID           uuid.UUID    `gorm:"type:varchar(36);primaryKey;column:id"`
+	PostID       uuid.UUID    `gorm:"type:varchar(36);uniqueIndex:idx_post_revisions_number,priority:1;column:post_id"`
+	Number       int          `gorm:"uniqueIndex:idx_post_revisions_number,priority:2;column:number"`
+	AuthorID     uuid.UUID    `gorm:"type:varchar(36);index;column:author_id"`
+	Category     string       `gorm:"column:category"`
+	Title        string       `gorm:"size:190;column:title"`
+	Description  string       `gorm:"column:description"`
+	RestoredFrom *int         `gorm:"column:restored_from"`
+	CreatedAt    time.Time    `gorm:"column:created_at"`
+	Post         lookup18Post `gorm:"foreignKey:PostID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (lookup18PostRevision) TableName() string {
+	return "post_revisions"
+}
+
+type lookup18Post struct {
+	ID          uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+	RoleID      uuid.UUID `gorm:"type:varchar(36);column:role_id"`
+	Category    string    `gorm:"column:category"`
+	Title       string    `gorm:"column:title"`
+	Description string    `gorm:"column:description"`
+	PostDate    time.Time `gorm:"column:post_date"`
+}
+
+func (lookup18Post) TableName() string {
+	return "posts"
+}
+
+func init() {
+	Register(Migration{
+		Version: 18,
+		Name:    "lookup18_post_revisions",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&lookup18PostRevision{}); err != nil {
+				return err
+			}
+
+			// the current content of the existing posts is their first revision, written by their author
+			posts := []lookup18Post{}
+			err := tx.Where("id NOT IN (?)", tx.Model(&lookup18PostRevision{}).Select("post_id")).Find(&posts).Error
+			if err != nil {
+				return err
+			}
+
+			for _, post := range posts {
+				revision := lookup18PostRevision{
+					ID:          uuid.New(),
+					PostID:      post.ID,
+					Number:      1,
+					AuthorID:    post.RoleID,
+					Category:    post.Category,
+					Title:       post.Title,
+					Description: post.Description,
+					CreatedAt:   post.PostDate,
+				}
+				if err := tx.Omit("Post").Create(&revision).Error; err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&lookup18PostRevision{})
+		},
+	})
+}
@@ -1,9 +1,78 @@
 package migrators
 
 import (
-	"blogpost/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-func (u *LookUpDb) Lookup2() {
-	u.DB.AutoMigrate(&models.User{}, &models.Post{}, &models.Comments{}, models.Views{})
+// the tables as they were at this version, spelled out so later changes to the models do not affect it
+type lookup2User struct {
+	ID       uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+	Role     string    `gorm:"column:role"`
+	Mail     string    `gorm:"unique;size:190;column:mail"`
+	Password string    `gorm:"column:password"`
+}
+
+func (lookup2User) TableName() string {
+	return "users"
+}
+
+type lookup2Post struct {
+	ID           uuid.UUID   `gorm:"type:varchar(36);primaryKey;column:id"`
+	RoleID       uuid.UUID   `gorm:"type:varchar(36);column:role_id"`
+	Category     string      `gorm:"column:category"`
+	Title        string      `gorm:"unique;size:190;column:title"`
+	Description  string      `gorm:"column:description"`
+	PostDate     time.Time   `gorm:"column:post_date"`
+	CommentCount uint        `gorm:"column:comment_count"`
+	ViewsCount   int         `gorm:"column:views_count"`
+	UserCount    int         `gorm:"column:user_count"`
+	User         lookup2User `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (lookup2Post) TableName() string {
+	return "posts"
+}
+
+type lookup2Comments struct {
+	ID       uuid.UUID   `gorm:"type:varchar(36);primaryKey;column:id"`
+	PostID   uuid.UUID   `gorm:"type:varchar(36);column:post_id"`
+	RoleID   uuid.UUID   `gorm:"type:varchar(36);column:role_id"`
+	Feedback string      `gorm:"column:feedback"`
+	User     lookup2User `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Post     lookup2Post `gorm:"foreignKey:PostID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (lookup2Comments) TableName() string {
+	return "comments"
+}
+
+type lookup2Views struct {
+	ID       uuid.UUID   `gorm:"type:varchar(36);column:id"`
+	Views    int         `gorm:"column:views"`
+	RoleID   uuid.UUID   `gorm:"type:varchar(36);column:role_id"`
+	PostID   uuid.UUID   `gorm:"type:varchar(36);column:post_id"`
+	IsViewed bool        `gorm:"column:is_valid"`
+	Post     lookup2Post `gorm:"foreignKey:PostID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	User     lookup2User `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (lookup2Views) TableName() string {
+	return "views"
+}
+
+func init() {
+	Register(Migration{
+		Version: 2,
+		Name:    "lookup2",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&lookup2User{}, &lookup2Post{}, &lookup2Comments{}, &lookup2Views{})
+		},
+		// lookup2 only re-synchronised the tables created by lookup1, there is nothing to undo
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
 }
@@ -1,11 +1,86 @@
 package migrators
 
 import (
-	"blogpost/models"
-	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-func (u *LookUpDb) Lookup3() {
-	fmt.Println("lookup3 called")
-	u.DB.AutoMigrate(&models.User{}, &models.Post{}, &models.Comments{}, models.Views{}, models.Category{})
+// the tables as they were at this version, spelled out so later changes to the models do not affect it
+type lookup3User struct {
+	ID       uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+	Role     string    `gorm:"column:role"`
+	Mail     string    `gorm:"unique;size:190;column:mail"`
+	Password string    `gorm:"column:password"`
+}
+
+func (lookup3User) TableName() string {
+	return "users"
+}
+
+type lookup3Post struct {
+	ID           uuid.UUID   `gorm:"type:varchar(36);primaryKey;column:id"`
+	RoleID       uuid.UUID   `gorm:"type:varchar(36);column:role_id"`
+	Category     string      `gorm:"column:category"`
+	Title        string      `gorm:"unique;size:190;column:title"`
+	Description  string      `gorm:"column:description"`
+	PostDate     time.Time   `gorm:"column:post_date"`
+	CommentCount uint        `gorm:"column:comment_count"`
+	ViewsCount   int         `gorm:"column:views_count"`
+	UserCount    int         `gorm:"column:user_count"`
+	User         lookup3User `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (lookup3Post) TableName() string {
+	return "posts"
+}
+
+type lookup3Comments struct {
+	ID       uuid.UUID   `gorm:"type:varchar(36);primaryKey;column:id"`
+	PostID   uuid.UUID   `gorm:"type:varchar(36);column:post_id"`
+	RoleID   uuid.UUID   `gorm:"type:varchar(36);column:role_id"`
+	Feedback string      `gorm:"column:feedback"`
+	User     lookup3User `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Post     lookup3Post `gorm:"foreignKey:PostID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (lookup3Comments) TableName() string {
+	return "comments"
+}
+
+type lookup3Views struct {
+	ID       uuid.UUID   `gorm:"type:varchar(36);column:id"`
+	Views    int         `gorm:"column:views"`
+	RoleID   uuid.UUID   `gorm:"type:varchar(36);column:role_id"`
+	PostID   uuid.UUID   `gorm:"type:varchar(36);column:post_id"`
+	IsViewed bool        `gorm:"column:is_valid"`
+	Post     lookup3Post `gorm:"foreignKey:PostID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	User     lookup3User `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (lookup3Views) TableName() string {
+	return "views"
+}
+
+type lookup3Category struct {
+	ID   uuid.UUID `gorm:"type:varchar(36);column:id"`
+	Name string    `gorm:"column:name"`
+}
+
+func (lookup3Category) TableName() string {
+	return "categories"
+}
+
+func init() {
+	Register(Migration{
+		Version: 3,
+		Name:    "lookup3",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&lookup3User{}, &lookup3Post{}, &lookup3Comments{}, &lookup3Views{}, &lookup3Category{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&lookup3Category{})
+		},
+	})
 }
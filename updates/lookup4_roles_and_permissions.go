@@ -0,0 +1,142 @@
+package migrators
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// the grants are spelled out here rather than taken from the rbac package so this migration never changes
+var lookup4Grants = map[string][]string{
+	"admin": {
+		"post:create", "post:read", "post:edit:own", "post:edit:any", "post:delete:own", "post:delete:any",
+		"comment:create", "comment:edit:own", "comment:delete:own", "comment:moderate", "role:assign",
+	},
+	"member": {
+		"post:read", "comment:create", "comment:edit:own", "comment:delete:own",
+	},
+}
+
+// the tables this migration adds and the users it reads, spelled out so later changes to the models do not affect it
+type lookup4User struct {
+	ID   uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+	Role string    `gorm:"column:role"`
+}
+
+func (lookup4User) TableName() string {
+	return "users"
+}
+
+type lookup4Permission struct {
+	ID   uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+	Name string    `gorm:"unique;size:64;column:name"`
+}
+
+func (lookup4Permission) TableName() string {
+	return "permissions"
+}
+
+type lookup4Role struct {
+	ID   uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+	Name string    `gorm:"unique;size:64;column:name"`
+}
+
+func (lookup4Role) TableName() string {
+	return "roles"
+}
+
+// the join table of the many2many between the roles and their permissions, written as its own table so gorm
+// does not name its columns and keys after the structs of this migration
+type lookup4RolePermission struct {
+	RoleID       uuid.UUID         `gorm:"type:varchar(36);primaryKey;column:role_id"`
+	PermissionID uuid.UUID         `gorm:"type:varchar(36);primaryKey;column:permission_id"`
+	Role         lookup4Role       `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Permission   lookup4Permission `gorm:"foreignKey:PermissionID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (lookup4RolePermission) TableName() string {
+	return "role_permissions"
+}
+
+type lookup4UserRole struct {
+	UserID uuid.UUID   `gorm:"type:varchar(36);primaryKey;column:user_id"`
+	RoleID uuid.UUID   `gorm:"type:varchar(36);primaryKey;column:role_id"`
+	User   lookup4User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Role   lookup4Role `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (lookup4UserRole) TableName() string {
+	return "user_roles"
+}
+
+func init() {
+	Register(Migration{
+		Version: 4,
+		Name:    "lookup4_roles_and_permissions",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&lookup4Permission{}, &lookup4Role{}, &lookup4RolePermission{}, &lookup4UserRole{}); err != nil {
+				return err
+			}
+
+			permissions := map[string]lookup4Permission{}
+			for _, grants := range lookup4Grants {
+				for _, name := range grants {
+					if _, ok := permissions[name]; ok {
+						continue
+					}
+
+					permission := lookup4Permission{ID: uuid.New(), Name: name}
+					if err := tx.Create(&permission).Error; err != nil {
+						return err
+					}
+					permissions[name] = permission
+				}
+			}
+
+			for name, grants := range lookup4Grants {
+				role := lookup4Role{ID: uuid.New(), Name: name}
+				if err := tx.Create(&role).Error; err != nil {
+					return err
+				}
+
+				for _, grant := range grants {
+					if err := tx.Omit("Role", "Permission").Create(&lookup4RolePermission{RoleID: role.ID, PermissionID: permissions[grant].ID}).Error; err != nil {
+						return err
+					}
+				}
+
+				// users were created with the role "user" before members had a name of their own
+				legacy := []string{name}
+				if name == "member" {
+					legacy = append(legacy, "user")
+				}
+
+				users := []lookup4User{}
+				if err := tx.Where("role IN ?", legacy).Find(&users).Error; err != nil {
+					return err
+				}
+
+				for _, user := range users {
+					if err := tx.Omit("User", "Role").Create(&lookup4UserRole{UserID: user.ID, RoleID: role.ID}).Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			return tx.Model(&lookup4User{}).Where("role=?", "user").Update("role", "member").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Model(&lookup4User{}).Where("role=?", "member").Update("role", "user").Error; err != nil {
+				return err
+			}
+
+			// dropped one at a time, the join table has to go before the tables it references
+			for _, table := range []interface{}{&lookup4UserRole{}, &lookup4RolePermission{}, &lookup4Role{}, &lookup4Permission{}} {
+				if err := tx.Migrator().DropTable(table); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	})
+}
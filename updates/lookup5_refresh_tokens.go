@@ -0,0 +1,46 @@
+package migrators
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// the table this migration adds, spelled out so later changes to the models do not affect it
+type lookup5RefreshToken struct {
+	ID           uuid.UUID   `gorm:"type:varchar(36);primaryKey;column:id"`
+	UserID       uuid.UUID   `gorm:"type:varchar(36);index;column:user_id"`
+	FamilyID     uuid.UUID   `gorm:"type:varchar(36);index;column:family_id"`
+	TokenHash    string      `gorm:"unique;size:64;column:token_hash"`
+	CreatedAt    time.Time   `gorm:"column:created_at"`
+	ExpiresAt    time.Time   `gorm:"column:expires_at"`
+	RevokedAt    *time.Time  `gorm:"column:revoked_at"`
+	ReplacedByID *uuid.UUID  `gorm:"type:varchar(36);column:replaced_by_id"`
+	User         lookup5User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (lookup5RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+type lookup5User struct {
+	ID uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+}
+
+func (lookup5User) TableName() string {
+	return "users"
+}
+
+func init() {
+	Register(Migration{
+		Version: 5,
+		Name:    "lookup5_refresh_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&lookup5RefreshToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&lookup5RefreshToken{})
+		},
+	})
+}
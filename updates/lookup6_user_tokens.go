@@ -0,0 +1,45 @@
+package migrators
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// the table this migration adds, spelled out so later changes to the models do not affect it
+type lookup6UserToken struct {
+	ID        uuid.UUID   `gorm:"type:varchar(36);primaryKey;column:id"`
+	UserID    uuid.UUID   `gorm:"type:varchar(36);index;column:user_id"`
+	Purpose   string      `gorm:"size:32;column:purpose"`
+	TokenHash string      `gorm:"unique;size:64;column:token_hash"`
+	CreatedAt time.Time   `gorm:"column:created_at"`
+	ExpiresAt time.Time   `gorm:"column:expires_at"`
+	UsedAt    *time.Time  `gorm:"column:used_at"`
+	User      lookup6User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (lookup6UserToken) TableName() string {
+	return "user_tokens"
+}
+
+type lookup6User struct {
+	ID uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+}
+
+func (lookup6User) TableName() string {
+	return "users"
+}
+
+func init() {
+	Register(Migration{
+		Version: 6,
+		Name:    "lookup6_user_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&lookup6UserToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&lookup6UserToken{})
+		},
+	})
+}
@@ -0,0 +1,36 @@
+package migrators
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lookup7User is the part of the users table this migration changes, later changes to models.User do not affect it
+type lookup7User struct {
+	EmailVerifiedAt *time.Time `gorm:"column:email_verified_at"`
+}
+
+func (lookup7User) TableName() string {
+	return "users"
+}
+
+func init() {
+	Register(Migration{
+		Version: 7,
+		Name:    "lookup7_email_verification",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&lookup7User{}, "EmailVerifiedAt") {
+				if err := tx.Migrator().AddColumn(&lookup7User{}, "EmailVerifiedAt"); err != nil {
+					return err
+				}
+			}
+
+			// the existing users signed up before verification existed and stay able to log in
+			return tx.Table("users").Where("email_verified_at IS NULL").Update("email_verified_at", time.Now()).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&lookup7User{}, "EmailVerifiedAt")
+		},
+	})
+}
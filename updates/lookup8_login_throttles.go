@@ -0,0 +1,90 @@
+package migrators
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// lookup8Grant lets the admins unlock accounts, spelled out rather than taken from the rbac package
+const lookup8Grant = "user:manage"
+
+// the table this migration adds and the rows it grants, spelled out so later changes to the models do not affect it
+type lookup8LoginThrottle struct {
+	Key           string     `gorm:"primaryKey;size:190;column:throttle_key"`
+	Failures      int        `gorm:"column:failures"`
+	LastFailureAt time.Time  `gorm:"column:last_failure_at"`
+	BlockedUntil  *time.Time `gorm:"column:blocked_until"`
+}
+
+func (lookup8LoginThrottle) TableName() string {
+	return "login_throttles"
+}
+
+type lookup8Permission struct {
+	ID   uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+	Name string    `gorm:"column:name"`
+}
+
+func (lookup8Permission) TableName() string {
+	return "permissions"
+}
+
+type lookup8Role struct {
+	ID   uuid.UUID `gorm:"type:varchar(36);primaryKey;column:id"`
+	Name string    `gorm:"column:name"`
+}
+
+func (lookup8Role) TableName() string {
+	return "roles"
+}
+
+type lookup8RolePermission struct {
+	RoleID       uuid.UUID `gorm:"type:varchar(36);primaryKey;column:role_id"`
+	PermissionID uuid.UUID `gorm:"type:varchar(36);primaryKey;column:permission_id"`
+}
+
+func (lookup8RolePermission) TableName() string {
+	return "role_permissions"
+}
+
+func init() {
+	Register(Migration{
+		Version: 8,
+		Name:    "lookup8_login_throttles",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&lookup8LoginThrottle{}); err != nil {
+				return err
+			}
+
+			permission := lookup8Permission{ID: uuid.New(), Name: lookup8Grant}
+			if err := tx.Create(&permission).Error; err != nil {
+				return err
+			}
+
+			admin := lookup8Role{}
+			if err := tx.Where("name=?", "admin").First(&admin).Error; err != nil {
+				return err
+			}
+
+			return tx.Create(&lookup8RolePermission{RoleID: admin.ID, PermissionID: permission.ID}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			permission := lookup8Permission{}
+			if err := tx.Where("name=?", lookup8Grant).First(&permission).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Where("permission_id=?", permission.ID).Delete(&lookup8RolePermission{}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Delete(&permission).Error; err != nil {
+				return err
+			}
+
+			return tx.Migrator().DropTable(&lookup8LoginThrottle{})
+		},
+	})
+}
@@ -0,0 +1,93 @@
+package migrators
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// the columns and the table this migration adds, spelled out so later changes to the models do not affect it.
+// The id of the users is only there for the recovery codes to reference.
+type lookup9User struct {
+	ID            uuid.UUID  `gorm:"type:varchar(36);primaryKey;column:id"`
+	TOTPSecret    string     `gorm:"size:64;column:totp_secret"`
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at"`
+	TOTPLastStep  int64      `gorm:"default:0;column:totp_last_step"`
+}
+
+func (lookup9User) TableName() string {
+	return "users"
+}
+
+type lookup9Role struct {
+	Require2FA bool `gorm:"default:false;column:require_2fa"`
+}
+
+func (lookup9Role) TableName() string {
+	return "roles"
+}
+
+type lookup9RefreshToken struct {
+	MFA bool `gorm:"default:false;column:mfa"`
+}
+
+func (lookup9RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+type lookup9RecoveryCode struct {
+	ID       uuid.UUID   `gorm:"type:varchar(36);primaryKey;column:id"`
+	UserID   uuid.UUID   `gorm:"type:varchar(36);index;column:user_id"`
+	CodeHash string      `gorm:"unique;size:64;column:code_hash"`
+	UsedAt   *time.Time  `gorm:"column:used_at"`
+	User     lookup9User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (lookup9RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+var lookup9Columns = []struct {
+	model interface{}
+	field string
+}{
+	{&lookup9User{}, "TOTPSecret"},
+	{&lookup9User{}, "TOTPEnabledAt"},
+	{&lookup9User{}, "TOTPLastStep"},
+	{&lookup9Role{}, "Require2FA"},
+	{&lookup9RefreshToken{}, "MFA"},
+}
+
+func init() {
+	Register(Migration{
+		Version: 9,
+		Name:    "lookup9_two_factor",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range lookup9Columns {
+				if tx.Migrator().HasColumn(column.model, column.field) {
+					continue
+				}
+
+				if err := tx.Migrator().AddColumn(column.model, column.field); err != nil {
+					return err
+				}
+			}
+
+			return tx.AutoMigrate(&lookup9RecoveryCode{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&lookup9RecoveryCode{}); err != nil {
+				return err
+			}
+
+			for _, column := range lookup9Columns {
+				if err := tx.Migrator().DropColumn(column.model, column.field); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	})
+}
@@ -2,10 +2,386 @@ package migrators
 
 import (
 	"blogpost/models"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"time"
 
 	"gorm.io/gorm"
 )
 
-func Migrations(db *gorm.DB) {
-	db.AutoMigrate(&models.LookUp{})
+// Migration is a single versioned schema change compiled into the binary.
+// Every lookupN.go file in this package registers one from its init function.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       func(tx *gorm.DB) error
+	Down     func(tx *gorm.DB) error
+	Checksum string
+}
+
+// the migration sources are embedded so the checksums can be computed without the source tree
+//
+//go:embed lookup*.go
+var sources embed.FS
+
+var registry = map[int]*Migration{}
+
+// Register adds a migration to the registry, its checksum is the sha256 of the file <Name>.go
+func Register(m Migration) {
+	if m.Version <= 0 || m.Name == "" || m.Up == nil || m.Down == nil {
+		panic(fmt.Sprintf("migration %d (%s) must have a positive version, a name, Up and Down", m.Version, m.Name))
+	}
+
+	if existing, ok := registry[m.Version]; ok {
+		panic(fmt.Sprintf("migration version %d registered twice: %s and %s", m.Version, existing.Name, m.Name))
+	}
+
+	source, err := sources.ReadFile(m.Name + ".go")
+	if err != nil {
+		panic(fmt.Sprintf("migration %d: source file %s.go is not embedded: %v", m.Version, m.Name, err))
+	}
+
+	sum := sha256.Sum256(source)
+	m.Checksum = hex.EncodeToString(sum[:])
+
+	registry[m.Version] = &m
+}
+
+// Registered returns all the registered migrations ordered by version
+func Registered() []*Migration {
+	migrations := make([]*Migration, 0, len(registry))
+	for _, m := range registry {
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations
+}
+
+// Migrations creates the lookup table the applied migrations are recorded in and the table used to lock it
+func Migrations(db *gorm.DB) error {
+	return db.AutoMigrate(&models.LookUp{}, &models.MigrationLock{})
+}
+
+type Migrator struct {
+	DB     *gorm.DB
+	Logger *log.Logger
+	// LockTimeout is how long Up, Down and Redo wait for another instance to finish migrating
+	LockTimeout time.Duration
+}
+
+func NewMigrator(db *gorm.DB, logger *log.Logger, lockTimeout time.Duration) *Migrator {
+	return &Migrator{DB: db, Logger: logger, LockTimeout: lockTimeout}
+}
+
+// applied returns the rows of the lookup table keyed by version
+func (m *Migrator) applied() (map[int]models.LookUp, error) {
+	if err := Migrations(m.DB); err != nil {
+		// another instance may have created the tables concurrently
+		if err := Migrations(m.DB); err != nil {
+			return nil, fmt.Errorf("error creating the lookup table: %v", err)
+		}
+	}
+
+	rows := []models.LookUp{}
+	if err := m.DB.Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error reading the lookup table: %v", err)
+	}
+
+	applied := make(map[int]models.LookUp, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	return applied, nil
+}
+
+// Verify refuses to continue when an applied migration differs from the one compiled into the binary
+// or when a previous run left a migration partially applied.
+// Rows recorded before checksums existed adopt the checksum of the compiled migration.
+func (m *Migrator) Verify() (map[int]models.LookUp, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	for version, row := range applied {
+		if row.Dirty {
+			return nil, fmt.Errorf("migration %d (%s) is dirty: a previous run failed part way through it. Repair the schema by hand, then run `blogpost migrate force %d` to mark it applied", version, row.Name, version)
+		}
+
+		migration, ok := registry[version]
+		if !ok {
+			m.Logger.Printf("Applied migration %d (%s) is not known to this binary", version, row.Name)
+			continue
+		}
+
+		if row.Checksum == "" {
+			row.Name = migration.Name
+			row.Checksum = migration.Checksum
+			if err := m.DB.Model(&models.LookUp{}).Where("id=?", row.ID).Updates(map[string]interface{}{"name": row.Name, "checksum": row.Checksum}).Error; err != nil {
+				return nil, fmt.Errorf("error recording the checksum of migration %d: %v", version, err)
+			}
+			applied[version] = row
+			m.Logger.Printf("Recorded checksum for previously applied migration %d (%s)", version, migration.Name)
+			continue
+		}
+
+		if row.Checksum != migration.Checksum {
+			return nil, fmt.Errorf("checksum mismatch for applied migration %d (%s): the database has %s but the binary has %s", version, migration.Name, row.Checksum, migration.Checksum)
+		}
+	}
+
+	return applied, nil
+}
+
+// Up applies the pending migrations in order, at most n of them when n is positive
+func (m *Migrator) Up(n int) error {
+	return m.withLock(func() error {
+		return m.up(n)
+	})
+}
+
+// Down rolls back the last n applied migrations in reverse order
+func (m *Migrator) Down(n int) error {
+	return m.withLock(func() error {
+		return m.down(n)
+	})
+}
+
+// Redo rolls back the last applied migration and applies it again
+func (m *Migrator) Redo() error {
+	return m.withLock(func() error {
+		if err := m.down(1); err != nil {
+			return err
+		}
+
+		return m.up(1)
+	})
+}
+
+// Force marks a dirty migration as cleanly applied once its schema has been repaired by hand
+func (m *Migrator) Force(version int) error {
+	return m.withLock(func() error {
+		result := m.DB.Model(&models.LookUp{}).Where("version=?", version).Where("dirty=?", true).Update("dirty", false)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("migration %d is not dirty", version)
+		}
+
+		m.Logger.Printf("Marked migration %d as applied", version)
+		return nil
+	})
+}
+
+func (m *Migrator) up(n int) error {
+	applied, err := m.Verify()
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, migration := range Registered() {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		if n > 0 && count == n {
+			break
+		}
+
+		if err := m.apply(migration); err != nil {
+			return err
+		}
+		count++
+	}
+
+	if count == 0 {
+		m.Logger.Println("No pending migrations")
+	}
+
+	return nil
+}
+
+func (m *Migrator) down(n int) error {
+	applied, err := m.Verify()
+	if err != nil {
+		return err
+	}
+
+	migrations := Registered()
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < n; i-- {
+		if _, ok := applied[migrations[i].Version]; !ok {
+			continue
+		}
+
+		if err := m.rollback(migrations[i]); err != nil {
+			return err
+		}
+		count++
+	}
+
+	if count == 0 {
+		m.Logger.Println("No applied migrations to roll back")
+	}
+
+	return nil
+}
+
+// transactionalDDL reports whether schema changes are rolled back with the transaction,
+// MySQL commits every DDL statement implicitly
+func (m *Migrator) transactionalDDL() bool {
+	return m.DB.Dialector.Name() != "mysql"
+}
+
+// apply runs the Up step and marks the migration applied within a single transaction.
+// The migration is recorded as dirty beforehand so a failure that can not be rolled back is detected on the next run.
+func (m *Migrator) apply(migration *Migration) error {
+	appliedAt := time.Now()
+	row := models.LookUp{
+		Name:      migration.Name,
+		Version:   migration.Version,
+		Checksum:  migration.Checksum,
+		AppliedAt: &appliedAt,
+		Dirty:     true,
+	}
+
+	if err := m.DB.Create(&row).Error; err != nil {
+		return fmt.Errorf("error recording migration %d (%s): %v", migration.Version, migration.Name, err)
+	}
+
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+
+		return tx.Model(&models.LookUp{}).Where("id=?", row.ID).Update("dirty", false).Error
+	})
+	if err != nil {
+		m.Logger.Printf("Error applying migration %d (%s): %v", migration.Version, migration.Name, err)
+		return m.failed(row, "applying", err)
+	}
+
+	m.Logger.Printf("Applied migration %d (%s)", migration.Version, migration.Name)
+	return nil
+}
+
+// rollback runs the Down step and removes the migration from the lookup table within a single transaction
+func (m *Migrator) rollback(migration *Migration) error {
+	row := models.LookUp{}
+	if err := m.DB.Where("version=?", migration.Version).First(&row).Error; err != nil {
+		return err
+	}
+
+	if err := m.DB.Model(&models.LookUp{}).Where("id=?", row.ID).Update("dirty", true).Error; err != nil {
+		return fmt.Errorf("error recording the rollback of migration %d (%s): %v", migration.Version, migration.Name, err)
+	}
+
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+
+		return tx.Where("id=?", row.ID).Delete(&models.LookUp{}).Error
+	})
+	if err != nil {
+		m.Logger.Printf("Error rolling back migration %d (%s): %v", migration.Version, migration.Name, err)
+		return m.failed(row, "rolling back", err)
+	}
+
+	m.Logger.Printf("Rolled back migration %d (%s)", migration.Version, migration.Name)
+	return nil
+}
+
+// failed restores the lookup row after a failed step when the schema change was rolled back,
+// otherwise the row stays dirty and the failure is reported as a partial migration
+func (m *Migrator) failed(row models.LookUp, action string, cause error) error {
+	if m.transactionalDDL() {
+		var err error
+		if action == "applying" {
+			err = m.DB.Where("id=?", row.ID).Delete(&models.LookUp{}).Error
+		} else {
+			err = m.DB.Model(&models.LookUp{}).Where("id=?", row.ID).Update("dirty", false).Error
+		}
+
+		if err == nil {
+			return fmt.Errorf("error %s migration %d (%s): %v", action, row.Version, row.Name, cause)
+		}
+		m.Logger.Printf("Error restoring the lookup row of migration %d: %v", row.Version, err)
+	}
+
+	return fmt.Errorf("error %s migration %d (%s), it may be partially applied and is marked dirty: %v", action, row.Version, row.Name, cause)
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified is set when the applied checksum differs from the compiled migration
+	Modified bool
+	// Unknown is set when the migration was applied but is not compiled into this binary
+	Unknown bool
+	// Dirty is set when a previous run failed part way through the migration
+	Dirty bool
+}
+
+// Status reports every compiled and applied migration ordered by version
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(registry))
+	for _, migration := range Registered() {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = row.AppliedAt
+			status.Modified = row.Checksum != "" && row.Checksum != migration.Checksum
+			status.Dirty = row.Dirty
+		}
+		statuses = append(statuses, status)
+	}
+
+	for version, row := range applied {
+		if _, ok := registry[version]; !ok {
+			statuses = append(statuses, MigrationStatus{Version: version, Name: row.Name, Applied: true, AppliedAt: row.AppliedAt, Unknown: true, Dirty: row.Dirty})
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// Pending returns the number of compiled migrations that have not been applied yet
+func (m *Migrator) Pending() (int, error) {
+	applied, err := m.Verify()
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for version := range registry {
+		if _, ok := applied[version]; !ok {
+			pending++
+		}
+	}
+
+	return pending, nil
 }
@@ -0,0 +1,78 @@
+package migrators
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const migrationTemplate = `package migrators
+
+import (
+	"gorm.io/gorm"
+)
+
+func init() {
+	Register(Migration{
+		Version: %d,
+		Name:    "%s",
+		Up: func(tx *gorm.DB) error {
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
+`
+
+var (
+	versionPattern = regexp.MustCompile(`^lookup(\d+)`)
+	namePattern    = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Create scaffolds a new migration file in dir with the next free version and returns its path
+func Create(dir, name string) (string, error) {
+	name = strings.Trim(namePattern.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", fmt.Errorf("migration name can not be empty")
+	}
+
+	version := 0
+	for _, migration := range Registered() {
+		if migration.Version > version {
+			version = migration.Version
+		}
+	}
+
+	// migrations written since the binary was built are only visible on disk
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("error reading the migrations directory: %v", err)
+	}
+
+	for _, file := range files {
+		match := versionPattern.FindStringSubmatch(file.Name())
+		if len(match) < 2 {
+			continue
+		}
+
+		number, err := strconv.Atoi(match[1])
+		if err == nil && number > version {
+			version = number
+		}
+	}
+
+	version++
+	migrationName := fmt.Sprintf("lookup%d_%s", version, name)
+	path := filepath.Join(dir, migrationName+".go")
+
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(migrationTemplate, version, migrationName)), 0644); err != nil {
+		return "", fmt.Errorf("error writing the migration file: %v", err)
+	}
+
+	return path, nil
+}